@@ -0,0 +1,3 @@
+# Firewalls can be imported by name or by ID
+terraform import shieldoo_firewall.default name:default
+terraform import shieldoo_firewall.default id:5e6f7a8b-9c0d-4e1f-8a2b-3c4d5e6f7a8b
@@ -0,0 +1,3 @@
+# Servers can be imported by name or by ID
+terraform import shieldoo_server.web name:web-01
+terraform import shieldoo_server.web id:0f3a2c1e-7b5d-4e8a-9c6f-1d2e3f4a5b6c
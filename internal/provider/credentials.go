@@ -0,0 +1,268 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
+	"gopkg.in/yaml.v3"
+)
+
+// apiKeyCommandTimeout bounds how long apikey_command may run.
+const apiKeyCommandTimeout = 30 * time.Second
+
+// maxServerClockSkew is the clock difference reported as the likely reason
+// of a rejected access token.
+const maxServerClockSkew = 30 * time.Second
+
+// warnSecondaryAPIKey adds a warning the first time client signs requests
+// with the secondary API key. Call it after every API call, the switch
+// happens on the first call the API rejects the primary key.
+func warnSecondaryAPIKey(client *shieldoo.Client, diags *diag.Diagnostics) {
+	if client == nil || !client.ReportSecondaryAPIKeySwitch() {
+		return
+	}
+	diags.AddWarning(
+		"Using the secondary Shieldoo API key",
+		"The API rejected apikey, the provider signs all requests with apikey_secondary for the rest of the run. Update apikey once the key rotation is finished.",
+	)
+}
+
+// shieldooProfile holds the settings of one profile of the credentials file.
+type shieldooProfile struct {
+	Endpoint string `yaml:"endpoint"`
+	ApiKey   string `yaml:"apikey"`
+}
+
+// loadProfile reads the named profile from the credentials file,
+// SHIELDOO_CREDENTIALS_FILE overrides the default ~/.shieldoo/credentials.
+func loadProfile(name string) (*shieldooProfile, error) {
+	path := os.Getenv("SHIELDOO_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".shieldoo", "credentials")
+	}
+	return parseProfile(path, name)
+}
+
+// parseProfile reads the named profile from an INI or YAML credentials file.
+// Files named *.yaml or *.yml are YAML, other files are YAML when they start
+// with a profile name followed by a colon instead of a [profile] header.
+func parseProfile(path string, name string) (*shieldooProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials file: %w", err)
+	}
+	var profile *shieldooProfile
+	if isYAMLCredentials(path, data) {
+		profile, err = parseYAMLProfile(path, data, name)
+	} else {
+		profile, err = parseINIProfile(path, data, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		return nil, fmt.Errorf("profile %q not found in %s", name, path)
+	}
+	return profile, nil
+}
+
+func isYAMLCredentials(path string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	case ".ini":
+		return false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || line == "---" {
+			continue
+		}
+		return strings.HasSuffix(line, ":")
+	}
+	return false
+}
+
+// parseINIProfile reads the named profile from an INI credentials file:
+//
+//	[production]
+//	endpoint = https://mytenant.shieldoo.net
+//	apikey   = AAABBBCCCDDD
+func parseINIProfile(path string, data []byte, name string) (*shieldooProfile, error) {
+	var profile *shieldooProfile
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: invalid section header", path, lineNo)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == name && profile == nil {
+				profile = &shieldooProfile{}
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNo)
+		}
+		if section != name {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.TrimSpace(key) {
+		case "endpoint":
+			profile.Endpoint = value
+		case "apikey":
+			profile.ApiKey = value
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown setting %q", path, lineNo, strings.TrimSpace(key))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// parseYAMLProfile reads the named profile from a YAML credentials file:
+//
+//	production:
+//	  endpoint: https://mytenant.shieldoo.net
+//	  apikey: AAABBBCCCDDD
+func parseYAMLProfile(path string, data []byte, name string) (*shieldooProfile, error) {
+	profiles := map[string]*shieldooProfile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&profiles); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	profile, ok := profiles[name]
+	if ok && profile == nil {
+		profile = &shieldooProfile{}
+	}
+	return profile, nil
+}
+
+// readApiKeyFile returns the API key stored in path.
+func readApiKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return key, nil
+}
+
+// runApiKeyCommand runs command with the system shell and returns the API
+// key it prints on stdout.
+func runApiKeyCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiKeyCommandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("command timed out after %s", apiKeyCommandTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	key := strings.TrimSpace(stdout.String())
+	if key == "" {
+		return "", errors.New("command printed no API key")
+	}
+	return key, nil
+}
+
+// credentialsErrorDiagnostic turns the error of the credential validation
+// call into a diagnostic summary and detail naming the likely cause.
+func credentialsErrorDiagnostic(instance string, err error) (string, string) {
+	hint := "\n\nSet skip_credentials_validation = true to skip this check."
+	var (
+		dnsErr       *net.DNSError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		certErr      x509.CertificateInvalidError
+		recordErr    tls.RecordHeaderError
+		opErr        *net.OpError
+		authErr      *shieldoo.AuthError
+		apiErr       *shieldoo.APIError
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return "Unable to resolve the Shieldoo endpoint",
+			fmt.Sprintf("The host %s could not be resolved: %s. Check the endpoint.", dnsErr.Name, err) + hint
+	case errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &certErr), errors.As(err, &recordErr):
+		return "TLS connection to the Shieldoo endpoint failed",
+			fmt.Sprintf("%s\n\nCheck the endpoint, trust a private CA with ca_cert_file or ca_cert_pem.", err) + hint
+	case errors.As(err, &opErr):
+		return "Unable to connect to the Shieldoo endpoint",
+			fmt.Sprintf("%s\n\nCheck the endpoint and proxy_url.", err) + hint
+	case errors.As(err, &authErr):
+		return "Unable to obtain a Shieldoo access token",
+			fmt.Sprintf("%s\n\nCheck the auth block of the provider configuration.", err) + hint
+	case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+		message := strings.ToLower(apiErr.Message)
+		skew := time.Duration(0)
+		if !apiErr.ServerTime.IsZero() {
+			skew = time.Since(apiErr.ServerTime)
+		}
+		switch {
+		case skew > maxServerClockSkew || skew < -maxServerClockSkew ||
+			strings.Contains(message, "expired") || strings.Contains(message, "before") || strings.Contains(message, "not valid yet"):
+			detail := fmt.Sprintf("The API rejected the access token: %s.", err)
+			if skew != 0 {
+				detail += fmt.Sprintf(" The local clock differs from the server clock by %s.", skew.Round(time.Second))
+			}
+			return "Shieldoo API rejected the access token because of clock skew",
+				detail + " Synchronize the local clock or set token_clock_skew." + hint
+		case strings.Contains(message, "instance"):
+			return "Shieldoo API rejected the instance claim",
+				fmt.Sprintf("The access token was issued for instance %q: %s. Check that the endpoint is the tenant URL the API key belongs to, or set instance when the endpoint is a proxy.", instance, err) + hint
+		default:
+			return "Invalid Shieldoo API key",
+				fmt.Sprintf("The API rejected the credentials: %s. Check the apikey and the endpoint.", err) + hint
+		}
+	default:
+		return "Unable to validate Shieldoo credentials", err.Error() + hint
+	}
+}
@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
+)
+
+func TestParseProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	content := `
+# tenants
+[default]
+endpoint = https://dev.shieldoo.net
+apikey = dev-key
+
+[production]
+endpoint = "https://prod.shieldoo.net"
+apikey   = prod-key
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := parseProfile(path, "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Endpoint != "https://prod.shieldoo.net" || profile.ApiKey != "prod-key" {
+		t.Fatalf("unexpected profile %+v", profile)
+	}
+
+	if _, err := parseProfile(path, "staging"); err == nil || !strings.Contains(err.Error(), `profile "staging" not found`) {
+		t.Fatalf("expected missing profile error, got %v", err)
+	}
+
+	t.Setenv("SHIELDOO_CREDENTIALS_FILE", path)
+	profile, err = loadProfile("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.ApiKey != "dev-key" {
+		t.Fatalf("unexpected profile %+v", profile)
+	}
+}
+
+func TestParseProfileYAML(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+# tenants
+default:
+  endpoint: https://dev.shieldoo.net
+  apikey: dev-key
+production:
+  endpoint: "https://prod.shieldoo.net"
+  apikey: prod-key
+`
+	// the format is taken from the extension, or from the content without one
+	for _, name := range []string{"credentials.yaml", "credentials"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		profile, err := parseProfile(path, "production")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if profile.Endpoint != "https://prod.shieldoo.net" || profile.ApiKey != "prod-key" {
+			t.Fatalf("%s: unexpected profile %+v", name, profile)
+		}
+		if _, err := parseProfile(path, "staging"); err == nil || !strings.Contains(err.Error(), `profile "staging" not found`) {
+			t.Fatalf("%s: expected missing profile error, got %v", name, err)
+		}
+	}
+
+	path := filepath.Join(dir, "typo.yml")
+	if err := os.WriteFile(path, []byte("default:\n  api_key: typo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseProfile(path, "default"); err == nil || !strings.Contains(err.Error(), "api_key") {
+		t.Fatalf("expected unknown setting error, got %v", err)
+	}
+}
+
+func TestParseProfileRejectsUnknownSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte("[default]\napi_key = typo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseProfile(path, "default"); err == nil || !strings.Contains(err.Error(), "unknown setting") {
+		t.Fatalf("expected unknown setting error, got %v", err)
+	}
+}
+
+func TestReadApiKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikey")
+	if err := os.WriteFile(path, []byte("file-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := readApiKeyFile(path)
+	if err != nil || key != "file-key" {
+		t.Fatalf("expected file-key, got %q %v", key, err)
+	}
+}
+
+func TestRunApiKeyCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	ctx := context.Background()
+	key, err := runApiKeyCommand(ctx, "echo command-key")
+	if err != nil || key != "command-key" {
+		t.Fatalf("expected command-key, got %q %v", key, err)
+	}
+	if _, err := runApiKeyCommand(ctx, "echo locked >&2; exit 1"); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("expected command error with stderr, got %v", err)
+	}
+}
+
+func TestCredentialsErrorDiagnostic(t *testing.T) {
+	instance := "mytenant.shieldoo.net"
+	endpoint := "https://" + instance
+	tests := map[string]struct {
+		err     error
+		summary string
+	}{
+		"dns": {
+			err:     &url.Error{Op: "Get", URL: endpoint, Err: &net.OpError{Op: "dial", Err: &net.DNSError{Name: "mytenant.shieldoo.net", Err: "no such host"}}},
+			summary: "Unable to resolve the Shieldoo endpoint",
+		},
+		"tls": {
+			err:     &url.Error{Op: "Get", URL: endpoint, Err: x509.UnknownAuthorityError{}},
+			summary: "TLS connection to the Shieldoo endpoint failed",
+		},
+		"bad key": {
+			err:     &shieldoo.APIError{StatusCode: http.StatusUnauthorized, Status: "401 Unauthorized", Message: "signature is invalid", ServerTime: time.Now()},
+			summary: "Invalid Shieldoo API key",
+		},
+		"expired token": {
+			err:     &shieldoo.APIError{StatusCode: http.StatusUnauthorized, Status: "401 Unauthorized", Message: "token is expired by 2m"},
+			summary: "Shieldoo API rejected the access token because of clock skew",
+		},
+		"server clock": {
+			err:     &shieldoo.APIError{StatusCode: http.StatusUnauthorized, Status: "401 Unauthorized", ServerTime: time.Now().Add(-10 * time.Minute)},
+			summary: "Shieldoo API rejected the access token because of clock skew",
+		},
+		"instance": {
+			err:     &shieldoo.APIError{StatusCode: http.StatusUnauthorized, Status: "401 Unauthorized", Message: `invalid instance claim "other.shieldoo.net"`},
+			summary: "Shieldoo API rejected the instance claim",
+		},
+		"token request": {
+			err:     &shieldoo.AuthError{Err: errors.New("invalid_client")},
+			summary: "Unable to obtain a Shieldoo access token",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			summary, detail := credentialsErrorDiagnostic(instance, tt.err)
+			if summary != tt.summary {
+				t.Fatalf("expected %q, got %q (%s)", tt.summary, summary, detail)
+			}
+			if !strings.Contains(detail, "skip_credentials_validation") {
+				t.Fatalf("expected detail to mention skip_credentials_validation: %s", detail)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
@@ -19,7 +20,7 @@ func NewFirewallDataSource() datasource.DataSource {
 
 // FirewallDataSource defines the data source implementation.
 type FirewallDataSource struct {
-	client *ShieldooClient
+	client *shieldoo.Client
 }
 
 // FirewallDataSourceModel describes the data source data model.
@@ -56,7 +57,7 @@ func (d *FirewallDataSource) Configure(ctx context.Context, req datasource.Confi
 		return
 	}
 
-	client, ok := req.ProviderData.(*ShieldooClient)
+	client, ok := req.ProviderData.(*shieldoo.Client)
 
 	if !ok {
 		resp.Diagnostics.AddError(
@@ -80,7 +81,8 @@ func (d *FirewallDataSource) Read(ctx context.Context, req datasource.ReadReques
 		return
 	}
 
-	firewall, err := d.client.GetFirewall(data.Name.ValueString())
+	firewall, err := d.client.GetFirewall(ctx, data.Name.ValueString())
+	warnSecondaryAPIKey(d.client, &resp.Diagnostics)
 	if err != nil {
 		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("ERROR: %s", err.Error()))
 		tflog.Error(ctx, "Client Error", map[string]interface{}{"err": err.Error()})
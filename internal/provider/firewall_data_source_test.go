@@ -7,15 +7,16 @@ import (
 )
 
 func TestAccFirewallDataSource(t *testing.T) {
+	fake := newTestAccFakeServer(t)
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
 			// Read testing
 			{
-				Config: testAccFirewallDataSourceConfig,
+				Config: fake.ProviderConfig() + testAccFirewallDataSourceConfig,
 				Check: resource.ComposeAggregateTestCheckFunc(
-					resource.TestCheckResourceAttr("data.shieldoo_firewall.test", "id", "mockup"),
+					resource.TestCheckResourceAttrPair("data.shieldoo_firewall.test", "id", "shieldoo_firewall.test", "id"),
 				),
 			},
 		},
@@ -23,11 +24,11 @@ func TestAccFirewallDataSource(t *testing.T) {
 }
 
 const testAccFirewallDataSourceConfig = `
-provider "shieldoo" {
-	endpoint = "https://mockup"
-	apikey = "mockup"
+resource "shieldoo_firewall" "test" {
+  name = "example"
 }
 data "shieldoo_firewall" "test" {
   name = "example"
+  depends_on = [shieldoo_firewall.test]
 }
 `
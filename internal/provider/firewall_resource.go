@@ -3,22 +3,29 @@ package provider
 import (
 	"context"
 	"fmt"
-	"regexp"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &FirewallResource{}
 var _ resource.ResourceWithImportState = &FirewallResource{}
+var _ resource.ResourceWithValidateConfig = &FirewallResource{}
+
+// firewallProtocols are the protocols of firewall rules.
+var firewallProtocols = []string{"any", "icmp", "tcp", "udp"}
 
 func NewFirewallResource() resource.Resource {
 	return &FirewallResource{}
@@ -26,7 +33,7 @@ func NewFirewallResource() resource.Resource {
 
 // FirewallResource defines the resource implementation.
 type FirewallResource struct {
-	client *ShieldooClient
+	client *shieldoo.Client
 }
 
 // FirewallResourceModel describes the resource data model.
@@ -37,6 +44,16 @@ type FirewallResourceModel struct {
 	RulesOutbound FirewallResourceModelRuleValue `tfsdk:"rules_outbound"`
 }
 
+// firewallRuleAttrTypes are the attribute types of a rules_inbound and
+// rules_outbound element.
+var firewallRuleAttrTypes = map[string]attr.Type{
+	"port":             types.StringType,
+	"protocol":         types.StringType,
+	"group_ids":        types.ListType{ElemType: types.StringType},
+	"group_object_ids": types.ListType{ElemType: types.StringType},
+	"group_names":      types.ListType{ElemType: types.StringType},
+}
+
 type FirewallResourceModelRuleType struct {
 	types.ListType
 }
@@ -59,8 +76,8 @@ type FirewallResourceModelRuleValue struct {
 	types.List
 }
 
-func (c FirewallResourceModelRuleValue) ParseFirewallRulesFromModel(ctx context.Context) []FirewallRule {
-	var rules []FirewallRule
+func (c FirewallResourceModelRuleValue) ParseFirewallRulesFromModel(ctx context.Context) []shieldoo.FirewallRule {
+	var rules []shieldoo.FirewallRule
 	for _, rule := range c.Elements() {
 		tflog.Debug(ctx, "parse rule", map[string]interface{}{"rule": rule})
 
@@ -79,7 +96,7 @@ func (c FirewallResourceModelRuleValue) ParseFirewallRulesFromModel(ctx context.
 			tflog.Warn(ctx, "rule has no protocol", map[string]interface{}{"rule": rule})
 			continue
 		}
-		r := FirewallRule{
+		r := shieldoo.FirewallRule{
 			Port:     port.ValueString(),
 			Protocol: protocol.ValueString(),
 		}
@@ -95,7 +112,7 @@ func (c FirewallResourceModelRuleValue) ParseFirewallRulesFromModel(ctx context.
 					tflog.Warn(ctx, "rule has no group_ids", map[string]interface{}{"rule": rule})
 					continue
 				}
-				r.Groups = append(r.Groups, Group{Id: tmp.ValueString()})
+				r.Groups = append(r.Groups, shieldoo.Group{Id: tmp.ValueString()})
 			}
 		}
 		if rule.Attributes()["group_names"] != nil {
@@ -110,7 +127,7 @@ func (c FirewallResourceModelRuleValue) ParseFirewallRulesFromModel(ctx context.
 					tflog.Warn(ctx, "rule has no group_names", map[string]interface{}{"rule": rule})
 					continue
 				}
-				r.Groups = append(r.Groups, Group{Name: tmp.ValueString()})
+				r.Groups = append(r.Groups, shieldoo.Group{Name: tmp.ValueString()})
 			}
 		}
 		if rule.Attributes()["group_object_ids"] != nil {
@@ -125,7 +142,7 @@ func (c FirewallResourceModelRuleValue) ParseFirewallRulesFromModel(ctx context.
 					tflog.Warn(ctx, "rule has no group_object_ids", map[string]interface{}{"rule": rule})
 					continue
 				}
-				r.Groups = append(r.Groups, Group{ObjectId: tmp.ValueString()})
+				r.Groups = append(r.Groups, shieldoo.Group{ObjectId: tmp.ValueString()})
 			}
 		}
 		rules = append(rules, r)
@@ -133,6 +150,53 @@ func (c FirewallResourceModelRuleValue) ParseFirewallRulesFromModel(ctx context.
 	return rules
 }
 
+// FirewallRulesToModel converts the rules returned by the API. The groups
+// of a rule are rendered the way the rule at the same position in prior
+// declared them, see renderGroupRefs.
+func FirewallRulesToModel(ctx context.Context, rules []shieldoo.FirewallRule, prior FirewallResourceModelRuleValue) (FirewallResourceModelRuleValue, diag.Diagnostics) {
+	elemType := types.ObjectType{AttrTypes: firewallRuleAttrTypes}
+	if len(rules) == 0 && prior.IsNull() {
+		return FirewallResourceModelRuleValue{types.ListNull(elemType)}, nil
+	}
+	var diags diag.Diagnostics
+	priorElements := prior.Elements()
+	elements := []attr.Value{}
+	for i, rule := range rules {
+		priorGroups := groupRefs{
+			Ids:       types.ListNull(types.StringType),
+			ObjectIds: types.ListNull(types.StringType),
+			Names:     types.ListNull(types.StringType),
+		}
+		if i < len(priorElements) {
+			if o, ok := priorElements[i].(types.Object); ok {
+				if l, ok := o.Attributes()["group_ids"].(types.List); ok {
+					priorGroups.Ids = l
+				}
+				if l, ok := o.Attributes()["group_object_ids"].(types.List); ok {
+					priorGroups.ObjectIds = l
+				}
+				if l, ok := o.Attributes()["group_names"].(types.List); ok {
+					priorGroups.Names = l
+				}
+			}
+		}
+		groups, d := renderGroupRefs(ctx, rule.Groups, priorGroups)
+		diags.Append(d...)
+		o, d := types.ObjectValue(firewallRuleAttrTypes, map[string]attr.Value{
+			"port":             types.StringValue(rule.Port),
+			"protocol":         types.StringValue(rule.Protocol),
+			"group_ids":        groups.Ids,
+			"group_object_ids": groups.ObjectIds,
+			"group_names":      groups.Names,
+		})
+		diags.Append(d...)
+		elements = append(elements, o)
+	}
+	list, d := types.ListValue(elemType, elements)
+	diags.Append(d...)
+	return FirewallResourceModelRuleValue{list}, diags
+}
+
 func (r *FirewallResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_firewall"
 }
@@ -152,32 +216,24 @@ func (r *FirewallResource) Schema(ctx context.Context, req resource.SchemaReques
 				MarkdownDescription: "Firewall inbound rules",
 				CustomType: FirewallResourceModelRuleType{
 					types.ListType{
-						ElemType: types.ObjectType{
-							AttrTypes: map[string]attr.Type{
-								"port":     types.StringType,
-								"protocol": types.StringType,
-								"group_ids": types.ListType{
-									ElemType: types.StringType,
-								},
-								"group_object_ids": types.ListType{
-									ElemType: types.StringType,
-								},
-								"group_names": types.ListType{
-									ElemType: types.StringType,
-								},
-							},
-						},
+						ElemType: types.ObjectType{AttrTypes: firewallRuleAttrTypes},
 					},
 				},
 				NestedObject: schema.NestedAttributeObject{
 					Attributes: map[string]schema.Attribute{
 						"port": schema.StringAttribute{
-							MarkdownDescription: "Port",
+							MarkdownDescription: "Port, port range (`start-end`) or `any`, must be `any` for `icmp`",
 							Required:            true,
+							Validators: []validator.String{
+								firewallPort(),
+							},
 						},
 						"protocol": schema.StringAttribute{
-							MarkdownDescription: "Protocol",
+							MarkdownDescription: "Protocol (`any`, `icmp`, `tcp` or `udp`)",
 							Required:            true,
+							Validators: []validator.String{
+								stringvalidator.OneOf(firewallProtocols...),
+							},
 						},
 						"group_ids": schema.ListAttribute{
 							MarkdownDescription: "Group IDs",
@@ -202,32 +258,24 @@ func (r *FirewallResource) Schema(ctx context.Context, req resource.SchemaReques
 				MarkdownDescription: "Firewall outbound rules",
 				CustomType: FirewallResourceModelRuleType{
 					types.ListType{
-						ElemType: types.ObjectType{
-							AttrTypes: map[string]attr.Type{
-								"port":     types.StringType,
-								"protocol": types.StringType,
-								"group_ids": types.ListType{
-									ElemType: types.StringType,
-								},
-								"group_object_ids": types.ListType{
-									ElemType: types.StringType,
-								},
-								"group_names": types.ListType{
-									ElemType: types.StringType,
-								},
-							},
-						},
+						ElemType: types.ObjectType{AttrTypes: firewallRuleAttrTypes},
 					},
 				},
 				NestedObject: schema.NestedAttributeObject{
 					Attributes: map[string]schema.Attribute{
 						"port": schema.StringAttribute{
-							MarkdownDescription: "Port",
+							MarkdownDescription: "Port, port range (`start-end`) or `any`, must be `any` for `icmp`",
 							Required:            true,
+							Validators: []validator.String{
+								firewallPort(),
+							},
 						},
 						"protocol": schema.StringAttribute{
-							MarkdownDescription: "Protocol",
+							MarkdownDescription: "Protocol (`any`, `icmp`, `tcp` or `udp`)",
 							Required:            true,
+							Validators: []validator.String{
+								stringvalidator.OneOf(firewallProtocols...),
+							},
 						},
 						"group_ids": schema.ListAttribute{
 							MarkdownDescription: "Group IDs",
@@ -264,7 +312,7 @@ func (r *FirewallResource) Configure(ctx context.Context, req resource.Configure
 		return
 	}
 
-	client, ok := req.ProviderData.(*ShieldooClient)
+	client, ok := req.ProviderData.(*shieldoo.Client)
 
 	if !ok {
 		resp.Diagnostics.AddError(
@@ -278,12 +326,55 @@ func (r *FirewallResource) Configure(ctx context.Context, req resource.Configure
 	r.client = client
 }
 
-func (r *FirewallResource) NormalizeFirewallRule(rule *FirewallRule) error {
-	if !regexp.MustCompile(`^(any|icmp|tcp|udp)$`).MatchString(rule.Protocol) {
+// ValidateConfig rejects ports on icmp rules, icmp has no ports.
+func (r *FirewallResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data FirewallResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	for _, rulesAttr := range []struct {
+		name  string
+		rules FirewallResourceModelRuleValue
+	}{
+		{"rules_inbound", data.RulesInbound},
+		{"rules_outbound", data.RulesOutbound},
+	} {
+		for i, rule := range rulesAttr.rules.Elements() {
+			rule, ok := rule.(types.Object)
+			if !ok || rule.IsNull() || rule.IsUnknown() {
+				continue
+			}
+			protocol, ok := rule.Attributes()["protocol"].(types.String)
+			if !ok || protocol.IsUnknown() || protocol.ValueString() != "icmp" {
+				continue
+			}
+			port, ok := rule.Attributes()["port"].(types.String)
+			if !ok || port.IsNull() || port.IsUnknown() || port.ValueString() == "any" {
+				continue
+			}
+			resp.Diagnostics.AddAttributeError(
+				path.Root(rulesAttr.name).AtListIndex(i).AtName("port"),
+				"Invalid Attribute Value",
+				fmt.Sprintf("icmp rules have no ports, port must be \"any\", got: %q", port.ValueString()),
+			)
+		}
+	}
+}
+
+func (r *FirewallResource) NormalizeFirewallRule(rule *shieldoo.FirewallRule) error {
+	validProtocol := false
+	for _, p := range firewallProtocols {
+		validProtocol = validProtocol || rule.Protocol == p
+	}
+	if !validProtocol {
 		return fmt.Errorf("invalid protocol: %s", rule.Protocol)
 	}
-	if !regexp.MustCompile(`^([1-9][0-9]{0,3}|[1-5][0-9]{4}|6[0-4][0-9]{3}|65[0-4][0-9]{2}|655[0-2][0-9]|6553[0-5])$|^([1-9][0-9]{0,3}|[1-5][0-9]{4}|6[0-4][0-9]{3}|65[0-4][0-9]{2}|655[0-2][0-9]|6553[0-5])-([1-9][0-9]{0,3}|[1-5][0-9]{4}|6[0-4][0-9]{3}|65[0-4][0-9]{2}|655[0-2][0-9]|6553[0-5])$|^any$`).MatchString(rule.Port) {
-		return fmt.Errorf("invalid port: %s", rule.Port)
+	if err := parseFirewallPort(rule.Port); err != nil {
+		return fmt.Errorf("invalid port %s: %w", rule.Port, err)
+	}
+	if rule.Protocol == "icmp" && rule.Port != "any" {
+		return fmt.Errorf("invalid port %s: icmp rules have no ports", rule.Port)
 	}
 
 	// normalize groups
@@ -301,7 +392,7 @@ func (r *FirewallResource) NormalizeFirewallRule(rule *FirewallRule) error {
 	return nil
 }
 
-func (r *FirewallResource) NormalizeFirewall(fw *Firewall) error {
+func (r *FirewallResource) NormalizeFirewall(fw *shieldoo.Firewall) error {
 	for i := range fw.RulesIn {
 		if err := r.NormalizeFirewallRule(&fw.RulesIn[i]); err != nil {
 			return err
@@ -314,17 +405,31 @@ func (r *FirewallResource) NormalizeFirewall(fw *Firewall) error {
 	}
 	// default OUT rules
 	if len(fw.RulesOut) == 0 {
-		fw.RulesOut = []FirewallRule{
-			{
-				Port:     "any",
-				Protocol: "any",
-				Host:     "any",
-			},
-		}
+		fw.RulesOut = defaultFirewallRulesOut()
 	}
 	return nil
 }
 
+// defaultFirewallRulesOut allow all outbound traffic, they are used when
+// rules_outbound is omitted.
+func defaultFirewallRulesOut() []shieldoo.FirewallRule {
+	return []shieldoo.FirewallRule{
+		{
+			Port:     "any",
+			Protocol: "any",
+			Host:     "any",
+		},
+	}
+}
+
+// isDefaultFirewallRulesOut reports whether rules are the rules created for
+// an omitted rules_outbound.
+func isDefaultFirewallRulesOut(rules []shieldoo.FirewallRule) bool {
+	def := defaultFirewallRulesOut()
+	return len(rules) == len(def) && len(rules[0].Groups) == 0 &&
+		rules[0].Port == def[0].Port && rules[0].Protocol == def[0].Protocol
+}
+
 func (r *FirewallResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	var data *FirewallResourceModel
 
@@ -335,7 +440,7 @@ func (r *FirewallResource) Create(ctx context.Context, req resource.CreateReques
 		return
 	}
 
-	firewall := &Firewall{
+	firewall := &shieldoo.Firewall{
 		Name:     data.Name.ValueString(),
 		RulesIn:  data.RulesInbound.ParseFirewallRulesFromModel(ctx),
 		RulesOut: data.RulesOutbound.ParseFirewallRulesFromModel(ctx),
@@ -347,7 +452,8 @@ func (r *FirewallResource) Create(ctx context.Context, req resource.CreateReques
 		return
 	}
 
-	firewall, err := r.client.CreateFirewall(firewall)
+	firewall, err := r.client.CreateFirewall(ctx, firewall)
+	warnSecondaryAPIKey(r.client, &resp.Diagnostics)
 	if err != nil {
 		resp.Diagnostics.AddError("Error creating firewall", err.Error())
 		tflog.Error(ctx, "error creating firewall", map[string]interface{}{"error": err.Error()})
@@ -373,7 +479,13 @@ func (r *FirewallResource) Read(ctx context.Context, req resource.ReadRequest, r
 		return
 	}
 
-	firewall, err := r.client.GetFirewall(data.Name.ValueString())
+	firewall, err := r.client.GetFirewallByID(ctx, data.Id.ValueString())
+	warnSecondaryAPIKey(r.client, &resp.Diagnostics)
+	if shieldoo.IsNotFound(err) {
+		tflog.Warn(ctx, "Firewall not found, removing from state", map[string]interface{}{"id": data.Id.ValueString()})
+		resp.State.RemoveResource(ctx)
+		return
+	}
 	if err != nil {
 		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to get Firewall, got error: %s", err))
 		tflog.Error(ctx, "Client Error", map[string]interface{}{"err": err.Error()})
@@ -381,6 +493,23 @@ func (r *FirewallResource) Read(ctx context.Context, req resource.ReadRequest, r
 	}
 
 	data.Id = types.StringValue(firewall.Id)
+	data.Name = types.StringValue(firewall.Name)
+
+	rulesIn, diags := FirewallRulesToModel(ctx, firewall.RulesIn, data.RulesInbound)
+	resp.Diagnostics.Append(diags...)
+	data.RulesInbound = rulesIn
+
+	rulesOut := firewall.RulesOut
+	if len(data.RulesOutbound.Elements()) == 0 && isDefaultFirewallRulesOut(rulesOut) {
+		// the default rules were created for the omitted or empty attribute
+		rulesOut = nil
+	}
+	rulesOutModel, diags := FirewallRulesToModel(ctx, rulesOut, data.RulesOutbound)
+	resp.Diagnostics.Append(diags...)
+	data.RulesOutbound = rulesOutModel
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
@@ -396,7 +525,7 @@ func (r *FirewallResource) Update(ctx context.Context, req resource.UpdateReques
 		return
 	}
 
-	firewall := &Firewall{
+	firewall := &shieldoo.Firewall{
 		Id:       data.Id.ValueString(),
 		Name:     data.Name.ValueString(),
 		RulesIn:  data.RulesInbound.ParseFirewallRulesFromModel(ctx),
@@ -409,7 +538,8 @@ func (r *FirewallResource) Update(ctx context.Context, req resource.UpdateReques
 		return
 	}
 
-	_, err := r.client.UpdateFirewall(firewall)
+	_, err := r.client.UpdateFirewall(ctx, firewall)
+	warnSecondaryAPIKey(r.client, &resp.Diagnostics)
 	if err != nil {
 		resp.Diagnostics.AddError("Error updating firewall", err.Error())
 		tflog.Error(ctx, "error updating firewall", map[string]interface{}{"error": err.Error()})
@@ -430,7 +560,8 @@ func (r *FirewallResource) Delete(ctx context.Context, req resource.DeleteReques
 		return
 	}
 
-	err := r.client.DeleteFirewall(data.Id.ValueString())
+	err := r.client.DeleteFirewall(ctx, data.Id.ValueString())
+	warnSecondaryAPIKey(r.client, &resp.Diagnostics)
 	if err != nil {
 		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to delete Firewall, got error: %s", err))
 		tflog.Error(ctx, "Client Error", map[string]interface{}{"err": err.Error()})
@@ -439,5 +570,21 @@ func (r *FirewallResource) Delete(ctx context.Context, req resource.DeleteReques
 }
 
 func (r *FirewallResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	byName, value, err := parseImportID(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid import ID", err.Error())
+		return
+	}
+	if byName {
+		firewall, err := r.client.GetFirewall(ctx, value)
+		warnSecondaryAPIKey(r.client, &resp.Diagnostics)
+		if err != nil {
+			resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to find Firewall %q, got error: %s", value, err))
+			tflog.Error(ctx, "Client Error", map[string]interface{}{"err": err.Error()})
+			return
+		}
+		value = firewall.Id
+	}
+	// Read populates the rest of the attributes
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), value)...)
 }
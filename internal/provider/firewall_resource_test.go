@@ -1,34 +1,72 @@
 package provider
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	fwresource "github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
 )
 
 func TestAccFirewallResource(t *testing.T) {
+	fake := newTestAccFakeServer(t)
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             fake.checkFirewallsDestroyed,
 		Steps: []resource.TestStep{
 			// Create and Read testing
 			{
-				Config: testAccFirewallResourceConfig("one"),
+				Config: fake.ProviderConfig() + testAccFirewallResourceConfig("one", "22"),
 				Check: resource.ComposeAggregateTestCheckFunc(
-					resource.TestCheckResourceAttr("shieldoo_firewall.test", "id", "mockup"),
+					resource.TestCheckResourceAttrSet("shieldoo_firewall.test", "id"),
+					resource.TestCheckResourceAttr("shieldoo_firewall.test", "name", "one"),
+					fake.checkFirewall("one", "22"),
 				),
 			},
 			// ImportState testing
 			{
-				ResourceName: "shieldoo_firewall.test",
-				ImportState:  true,
+				ResourceName:      "shieldoo_firewall.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				// imported rules reference their groups by ID, the config uses names
+				ImportStateVerifyIgnore: []string{"rules_inbound.0.group_ids", "rules_inbound.0.group_names"},
 			},
-			// Update and Read testing
+			// Import by name
 			{
-				Config: testAccFirewallResourceConfig("two"),
+				ResourceName:            "shieldoo_firewall.test",
+				ImportState:             true,
+				ImportStateId:           "name:one",
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"rules_inbound.0.group_ids", "rules_inbound.0.group_names"},
+			},
+			// Drift made outside of Terraform is detected and reverted
+			{
+				PreConfig: func() { fake.driftFirewall(t, "one") },
+				Config:    fake.ProviderConfig() + testAccFirewallResourceConfig("one", "22"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("shieldoo_firewall.test", "rules_inbound.#", "1"),
+					resource.TestCheckResourceAttr("shieldoo_firewall.test", "rules_inbound.0.port", "22"),
+					resource.TestCheckNoResourceAttr("shieldoo_firewall.test", "rules_outbound"),
+					fake.checkFirewall("one", "22"),
+				),
+			},
+			// Rename and Read testing
+			{
+				Config: fake.ProviderConfig() + testAccFirewallResourceConfig("two", "443"),
 				Check: resource.ComposeAggregateTestCheckFunc(
-					resource.TestCheckResourceAttr("shieldoo_firewall.test", "id", "mockup"),
+					resource.TestCheckResourceAttrSet("shieldoo_firewall.test", "id"),
+					resource.TestCheckResourceAttr("shieldoo_firewall.test", "name", "two"),
+					fake.checkFirewall("two", "443"),
 				),
 			},
 			// Delete testing automatically occurs in TestCase
@@ -36,14 +74,134 @@ func TestAccFirewallResource(t *testing.T) {
 	})
 }
 
-func testAccFirewallResourceConfig(configurableAttribute string) string {
+func testAccFirewallResourceConfig(name string, port string) string {
 	return fmt.Sprintf(`
-provider "shieldoo" {
-	endpoint = "https://mockup"
-	apikey = "mockup"
-}
 resource "shieldoo_firewall" "test" {
   name = %[1]q
+  rules_inbound = [
+    {
+      protocol    = "tcp"
+      port        = %[2]q
+      group_names = ["admins"]
+    },
+  ]
+}
+`, name, port)
+}
+
+func (f *testAccFakeServer) checkFirewall(name string, port string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		fw, err := f.client.GetFirewall(context.Background(), name)
+		if err != nil {
+			return fmt.Errorf("firewall %s not found on the server: %w", name, err)
+		}
+		if len(fw.RulesIn) != 1 || fw.RulesIn[0].Port != port || len(fw.RulesIn[0].Groups) != 1 || fw.RulesIn[0].Groups[0].Id != "group-admins" {
+			return fmt.Errorf("unexpected inbound rules of firewall %s: %+v", name, fw.RulesIn)
+		}
+		return nil
+	}
+}
+
+// driftFirewall changes the firewall rules the way an administrator would
+// in the UI.
+func (f *testAccFakeServer) driftFirewall(t *testing.T, name string) {
+	ctx := context.Background()
+	fw, err := f.client.GetFirewall(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.RulesIn[0].Port = "2222"
+	fw.RulesIn = append(fw.RulesIn, shieldoo.FirewallRule{Protocol: "icmp", Port: "any", Host: "any"})
+	if _, err := f.client.UpdateFirewall(ctx, fw); err != nil {
+		t.Fatal(err)
+	}
 }
-`, configurableAttribute)
+
+func (f *testAccFakeServer) checkFirewallsDestroyed(s *terraform.State) error {
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "shieldoo_firewall" {
+			continue
+		}
+		_, err := f.client.GetFirewallByID(context.Background(), rs.Primary.ID)
+		if err == nil {
+			return fmt.Errorf("firewall %s still exists", rs.Primary.ID)
+		}
+		if !shieldoo.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestFirewallRulesToModel(t *testing.T) {
+	ctx := context.Background()
+	names, _ := types.ListValueFrom(ctx, types.StringType, []string{"admins"})
+	priorRule, diags := types.ObjectValue(firewallRuleAttrTypes, map[string]attr.Value{
+		"port":             types.StringValue("22"),
+		"protocol":         types.StringValue("tcp"),
+		"group_ids":        types.ListNull(types.StringType),
+		"group_object_ids": types.ListNull(types.StringType),
+		"group_names":      names,
+	})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	priorList, diags := types.ListValue(types.ObjectType{AttrTypes: firewallRuleAttrTypes}, []attr.Value{priorRule})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	got, diags := FirewallRulesToModel(ctx, []shieldoo.FirewallRule{
+		{Protocol: "tcp", Port: "2222", Host: "group", Groups: []shieldoo.Group{{Id: "group-admins", Name: "admins"}}},
+		{Protocol: "icmp", Port: "any", Host: "any"},
+	}, FirewallResourceModelRuleValue{priorList})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	rules := got.ParseFirewallRulesFromModel(ctx)
+	if len(rules) != 2 || rules[0].Port != "2222" || len(rules[0].Groups) != 1 || rules[0].Groups[0].Name != "admins" || rules[1].Protocol != "icmp" {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+	second := got.Elements()[1].(types.Object).Attributes()
+	if !second["group_ids"].IsNull() || !second["group_names"].IsNull() {
+		t.Fatalf("expected rule without groups to keep null group lists, got %v", second)
+	}
+
+	if !isDefaultFirewallRulesOut(defaultFirewallRulesOut()) || isDefaultFirewallRulesOut(rules) {
+		t.Fatal("unexpected default outbound rules detection")
+	}
+}
+
+func TestFirewallResourceValidateConfigIcmpPort(t *testing.T) {
+	ctx := context.Background()
+	r := &FirewallResource{}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	rulesOut, diags := FirewallRulesToModel(ctx, []shieldoo.FirewallRule{
+		{Protocol: "icmp", Port: "any"},
+		{Protocol: "icmp", Port: "8"},
+	}, FirewallResourceModelRuleValue{types.ListNull(types.ObjectType{AttrTypes: firewallRuleAttrTypes})})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	plan := tfsdk.Plan{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	diags = plan.Set(ctx, &FirewallResourceModel{
+		Name:          types.StringValue("default"),
+		RulesInbound:  FirewallResourceModelRuleValue{types.ListNull(types.ObjectType{AttrTypes: firewallRuleAttrTypes})},
+		RulesOutbound: rulesOut,
+	})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	resp := &fwresource.ValidateConfigResponse{}
+	r.ValidateConfig(ctx, fwresource.ValidateConfigRequest{Config: tfsdk.Config{Schema: plan.Schema, Raw: plan.Raw}}, resp)
+	if resp.Diagnostics.ErrorsCount() != 1 {
+		t.Fatalf("expected one icmp port error, got %v", resp.Diagnostics)
+	}
+	errPath := resp.Diagnostics.Errors()[0].(diag.DiagnosticWithPath).Path()
+	if !errPath.Equal(path.Root("rules_outbound").AtListIndex(1).AtName("port")) {
+		t.Fatalf("unexpected error path %s: %v", errPath, resp.Diagnostics)
+	}
 }
@@ -3,14 +3,21 @@ package provider
 import (
 	"context"
 	"os"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
 )
 
+// defaultCacheTTL is how long read-only API responses are cached within one
+// provider run.
+const defaultCacheTTL = 60 * time.Second
+
 // Ensure ShieldooProvider satisfies various provider interfaces.
 var _ provider.Provider = &ShieldooProvider{}
 
@@ -24,8 +31,57 @@ type ShieldooProvider struct {
 
 // SshieldooProviderModel describes the provider data model.
 type ShieldooProviderModel struct {
-	Endpoint types.String `tfsdk:"endpoint"`
-	ApiKey   types.String `tfsdk:"apikey"`
+	Endpoint        types.String `tfsdk:"endpoint"`
+	ApiKey          types.String `tfsdk:"apikey"`
+	ApiKeySecondary types.String `tfsdk:"apikey_secondary"`
+	ApiKeyFile      types.String `tfsdk:"apikey_file"`
+	ApiKeyCommand   types.String `tfsdk:"apikey_command"`
+	Profile         types.String `tfsdk:"profile"`
+	Instance        types.String `tfsdk:"instance"`
+	MaxRetries      types.Int64  `tfsdk:"max_retries"`
+	RetryMaxWait    types.Int64  `tfsdk:"retry_max_wait"`
+
+	MaxRequestsPerSecond  types.Float64 `tfsdk:"max_requests_per_second"`
+	MaxConcurrentRequests types.Int64   `tfsdk:"max_concurrent_requests"`
+	CacheTTL              types.Int64   `tfsdk:"cache_ttl"`
+
+	TokenLifetime  types.Int64 `tfsdk:"token_lifetime"`
+	TokenClockSkew types.Int64 `tfsdk:"token_clock_skew"`
+	TokenClaims    types.Map   `tfsdk:"token_claims"`
+
+	CACertFile         types.String `tfsdk:"ca_cert_file"`
+	CACertPEM          types.String `tfsdk:"ca_cert_pem"`
+	ClientCertFile     types.String `tfsdk:"client_cert_file"`
+	ClientKeyFile      types.String `tfsdk:"client_key_file"`
+	ClientCertPEM      types.String `tfsdk:"client_cert_pem"`
+	ClientKeyPEM       types.String `tfsdk:"client_key_pem"`
+	ProxyURL           types.String `tfsdk:"proxy_url"`
+	InsecureSkipVerify types.Bool   `tfsdk:"insecure_skip_verify"`
+	RequestTimeout     types.Int64  `tfsdk:"request_timeout"`
+
+	SkipCredentialsValidation types.Bool `tfsdk:"skip_credentials_validation"`
+
+	Auth *ShieldooProviderAuthModel `tfsdk:"auth"`
+}
+
+// ShieldooProviderAuthModel describes the auth block, when it is omitted the
+// provider signs its requests with the apikey.
+type ShieldooProviderAuthModel struct {
+	ClientCredentials *ShieldooProviderClientCredentialsModel `tfsdk:"client_credentials"`
+	WorkloadIdentity  *ShieldooProviderWorkloadIdentityModel  `tfsdk:"workload_identity"`
+}
+
+type ShieldooProviderClientCredentialsModel struct {
+	TokenURL     types.String `tfsdk:"token_url"`
+	ClientID     types.String `tfsdk:"client_id"`
+	ClientSecret types.String `tfsdk:"client_secret"`
+	Scopes       types.List   `tfsdk:"scopes"`
+}
+
+type ShieldooProviderWorkloadIdentityModel struct {
+	ExchangeURL types.String `tfsdk:"exchange_url"`
+	TokenFile   types.String `tfsdk:"token_file"`
+	Audience    types.String `tfsdk:"audience"`
 }
 
 func (p *ShieldooProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
@@ -37,7 +93,7 @@ func (p *ShieldooProvider) Schema(ctx context.Context, req provider.SchemaReques
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
 			"endpoint": schema.StringAttribute{
-				MarkdownDescription: "Shieldoo API endpoint",
+				MarkdownDescription: "Shieldoo API endpoint, use `file:///path/state.json` for the offline backend which keeps all data in a local file",
 				Optional:            true,
 			},
 			"apikey": schema.StringAttribute{
@@ -45,6 +101,148 @@ func (p *ShieldooProvider) Schema(ctx context.Context, req provider.SchemaReques
 				Optional:            true,
 				Sensitive:           true,
 			},
+			"apikey_secondary": schema.StringAttribute{
+				MarkdownDescription: "Secondary Shieldoo API Key used during key rotation when the API rejects `apikey`, can also be set with `SHIELDOO_API_KEY_SECONDARY`",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"apikey_file": schema.StringAttribute{
+				MarkdownDescription: "Path to a file containing the Shieldoo API Key",
+				Optional:            true,
+			},
+			"apikey_command": schema.StringAttribute{
+				MarkdownDescription: "Shell command printing the Shieldoo API Key on stdout, e.g. `vault kv get -field=apikey secret/shieldoo`",
+				Optional:            true,
+			},
+			"profile": schema.StringAttribute{
+				MarkdownDescription: "Profile in the INI or YAML file `~/.shieldoo/credentials` (or `SHIELDOO_CREDENTIALS_FILE`) providing the endpoint and API Key, can also be set with `SHIELDOO_PROFILE`",
+				Optional:            true,
+			},
+			"instance": schema.StringAttribute{
+				MarkdownDescription: "Shieldoo instance (tenant domain) put in the API access token, default is the endpoint hostname. Set it when the endpoint is a proxy with a different hostname, can also be set with `SHIELDOO_INSTANCE`",
+				Optional:            true,
+			},
+			"max_retries": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of retries for transient API failures (429, 502, 503, 504), default 3. Create requests are not retried, they may already have been applied",
+				Optional:            true,
+			},
+			"retry_max_wait": schema.Int64Attribute{
+				MarkdownDescription: "Maximum wait between retries in seconds, default 30",
+				Optional:            true,
+			},
+			"max_requests_per_second": schema.Float64Attribute{
+				MarkdownDescription: "Maximum number of API requests per second sent by the provider (unlimited if omitted)",
+				Optional:            true,
+			},
+			"max_concurrent_requests": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of API requests in flight at the same time (unlimited if omitted)",
+				Optional:            true,
+			},
+			"cache_ttl": schema.Int64Attribute{
+				MarkdownDescription: "How long read-only API responses are cached within one provider run in seconds, 0 disables the cache, default 60",
+				Optional:            true,
+			},
+			"token_lifetime": schema.Int64Attribute{
+				MarkdownDescription: "Lifetime of the signed API access token in seconds, default 300",
+				Optional:            true,
+			},
+			"token_clock_skew": schema.Int64Attribute{
+				MarkdownDescription: "Allowed clock skew in seconds, the token `iat`/`nbf` claims are backdated by this value, default 0",
+				Optional:            true,
+			},
+			"token_claims": schema.MapAttribute{
+				MarkdownDescription: "Additional claims added to the `shieldoo` claim of the API access token",
+				Optional:            true,
+				ElementType:         types.StringType,
+			},
+			"ca_cert_file": schema.StringAttribute{
+				MarkdownDescription: "Path to a PEM file with additional CA certificates trusted for the endpoint",
+				Optional:            true,
+			},
+			"ca_cert_pem": schema.StringAttribute{
+				MarkdownDescription: "PEM encoded CA certificates trusted for the endpoint",
+				Optional:            true,
+			},
+			"client_cert_file": schema.StringAttribute{
+				MarkdownDescription: "Path to a PEM client certificate used for mutual TLS",
+				Optional:            true,
+			},
+			"client_key_file": schema.StringAttribute{
+				MarkdownDescription: "Path to the PEM private key of the client certificate",
+				Optional:            true,
+			},
+			"client_cert_pem": schema.StringAttribute{
+				MarkdownDescription: "PEM encoded client certificate used for mutual TLS",
+				Optional:            true,
+			},
+			"client_key_pem": schema.StringAttribute{
+				MarkdownDescription: "PEM encoded private key of the client certificate",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"proxy_url": schema.StringAttribute{
+				MarkdownDescription: "HTTP proxy URL (if omitted, HTTPS_PROXY/HTTP_PROXY environment variables are used)",
+				Optional:            true,
+			},
+			"insecure_skip_verify": schema.BoolAttribute{
+				MarkdownDescription: "Skip TLS certificate verification (do not use in production)",
+				Optional:            true,
+			},
+			"request_timeout": schema.Int64Attribute{
+				MarkdownDescription: "Timeout of a single API request in seconds, default 60",
+				Optional:            true,
+			},
+			"skip_credentials_validation": schema.BoolAttribute{
+				MarkdownDescription: "Skip the API call validating the endpoint and credentials when the provider is configured",
+				Optional:            true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"auth": schema.SingleNestedBlock{
+				MarkdownDescription: "Alternative authentication, `apikey` is not used when a method is configured",
+				Blocks: map[string]schema.Block{
+					"client_credentials": schema.SingleNestedBlock{
+						MarkdownDescription: "OAuth2 client credentials grant, the provider sends the obtained access token as a bearer token and refreshes it before it expires",
+						Attributes: map[string]schema.Attribute{
+							"token_url": schema.StringAttribute{
+								MarkdownDescription: "Token endpoint of the identity provider",
+								Optional:            true,
+							},
+							"client_id": schema.StringAttribute{
+								MarkdownDescription: "OAuth2 client ID",
+								Optional:            true,
+							},
+							"client_secret": schema.StringAttribute{
+								MarkdownDescription: "OAuth2 client secret",
+								Optional:            true,
+								Sensitive:           true,
+							},
+							"scopes": schema.ListAttribute{
+								MarkdownDescription: "Scopes requested for the access token",
+								Optional:            true,
+								ElementType:         types.StringType,
+							},
+						},
+					},
+					"workload_identity": schema.SingleNestedBlock{
+						MarkdownDescription: "Exchange an OIDC token issued by the CI system for a Shieldoo session token. The token is read from `token_file`, the `TFC_WORKLOAD_IDENTITY_TOKEN` environment variable of Terraform Cloud or the GitHub Actions OIDC token endpoint",
+						Attributes: map[string]schema.Attribute{
+							"exchange_url": schema.StringAttribute{
+								MarkdownDescription: "Shieldoo token exchange endpoint, default `<endpoint>/cliapi/token`",
+								Optional:            true,
+							},
+							"token_file": schema.StringAttribute{
+								MarkdownDescription: "Path to a file with the OIDC token",
+								Optional:            true,
+							},
+							"audience": schema.StringAttribute{
+								MarkdownDescription: "Audience requested for GitHub Actions OIDC tokens, default `shieldoo`",
+								Optional:            true,
+							},
+						},
+					},
+				},
+			},
 		},
 	}
 }
@@ -54,6 +252,7 @@ func (p *ShieldooProvider) Configure(ctx context.Context, req provider.Configure
 
 	apiKey := os.Getenv("SHIELDOO_API_KEY")
 	endpoint := os.Getenv("SHIELDOO_ENDPOINT")
+	profileName := os.Getenv("SHIELDOO_PROFILE")
 
 	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
 
@@ -61,10 +260,47 @@ func (p *ShieldooProvider) Configure(ctx context.Context, req provider.Configure
 		return
 	}
 
+	if data.Profile.ValueString() != "" {
+		profileName = data.Profile.ValueString()
+	}
+
+	// a profile overrides the environment, the HCL settings override both
+	if profileName != "" {
+		profile, err := loadProfile(profileName)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"invalid profile",
+				err.Error(),
+			)
+			return
+		}
+		tflog.Info(ctx, "using Shieldoo credentials profile", map[string]interface{}{"profile": profileName})
+		if profile.Endpoint != "" {
+			endpoint = profile.Endpoint
+		}
+		if profile.ApiKey != "" {
+			apiKey = profile.ApiKey
+		}
+	}
+
 	if data.Endpoint.ValueString() != "" {
 		endpoint = data.Endpoint.ValueString()
 	}
 
+	apiKeySources := 0
+	for _, v := range []types.String{data.ApiKey, data.ApiKeyFile, data.ApiKeyCommand} {
+		if v.ValueString() != "" {
+			apiKeySources++
+		}
+	}
+	if apiKeySources > 1 {
+		resp.Diagnostics.AddError(
+			"conflicting apikey settings",
+			"Only one of apikey, apikey_file and apikey_command can be set.",
+		)
+		return
+	}
+
 	if data.ApiKey.ValueString() != "" {
 		apiKey = data.ApiKey.ValueString()
 	}
@@ -78,18 +314,255 @@ func (p *ShieldooProvider) Configure(ctx context.Context, req provider.Configure
 		return
 	}
 
-	if apiKey == "" {
+	if shieldoo.IsOfflineEndpoint(endpoint) {
+		tflog.Info(ctx, "using offline Shieldoo backend", map[string]interface{}{"endpoint": endpoint})
+		client, err := shieldoo.NewClient(endpoint)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"invalid offline endpoint",
+				err.Error(),
+			)
+			return
+		}
+		resp.DataSourceData = client
+		resp.ResourceData = client
+		return
+	}
+
+	var opts []shieldoo.Option
+	if data.Auth != nil && data.Auth.ClientCredentials != nil && data.Auth.WorkloadIdentity != nil {
+		resp.Diagnostics.AddError(
+			"invalid auth",
+			"Only one of client_credentials and workload_identity can be configured.",
+		)
+		return
+	}
+	if data.Auth != nil && data.Auth.WorkloadIdentity != nil {
+		wi := data.Auth.WorkloadIdentity
+		opts = append(opts, shieldoo.WithWorkloadIdentity(shieldoo.WorkloadIdentityConfig{
+			ExchangeURL: wi.ExchangeURL.ValueString(),
+			TokenFile:   wi.TokenFile.ValueString(),
+			Audience:    wi.Audience.ValueString(),
+		}))
+	} else if data.Auth != nil && data.Auth.ClientCredentials != nil {
+		cc := data.Auth.ClientCredentials
+		if cc.TokenURL.ValueString() == "" || cc.ClientID.ValueString() == "" || cc.ClientSecret.ValueString() == "" {
+			resp.Diagnostics.AddError(
+				"invalid auth.client_credentials",
+				"token_url, client_id and client_secret are required for the client credentials authentication.",
+			)
+			return
+		}
+		var scopes []string
+		resp.Diagnostics.Append(cc.Scopes.ElementsAs(ctx, &scopes, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		opts = append(opts, shieldoo.WithClientCredentials(shieldoo.ClientCredentialsConfig{
+			TokenURL:     cc.TokenURL.ValueString(),
+			ClientID:     cc.ClientID.ValueString(),
+			ClientSecret: cc.ClientSecret.ValueString(),
+			Scopes:       scopes,
+		}))
+	} else {
+		// the key is read only when it is needed, the command may prompt or cost time
+		if data.ApiKeyFile.ValueString() != "" {
+			key, err := readApiKeyFile(data.ApiKeyFile.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError(
+					"invalid apikey_file",
+					err.Error(),
+				)
+				return
+			}
+			apiKey = key
+		}
+		if data.ApiKeyCommand.ValueString() != "" {
+			key, err := runApiKeyCommand(ctx, data.ApiKeyCommand.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError(
+					"apikey_command failed",
+					err.Error(),
+				)
+				return
+			}
+			apiKey = key
+		}
+		if apiKey == "" {
+			resp.Diagnostics.AddError(
+				"apikey is required",
+				"Please set the apikey, apikey_file, apikey_command or profile in the provider configuration block.",
+			)
+			return
+		}
+		opts = append(opts, shieldoo.WithAPIKey(apiKey))
+
+		apiKeySecondary := os.Getenv("SHIELDOO_API_KEY_SECONDARY")
+		if data.ApiKeySecondary.ValueString() != "" {
+			apiKeySecondary = data.ApiKeySecondary.ValueString()
+		}
+		if apiKeySecondary != "" {
+			opts = append(opts, shieldoo.WithSecondaryAPIKey(apiKeySecondary))
+		}
+	}
+
+	maxRetries := shieldoo.DefaultMaxRetries
+	if !data.MaxRetries.IsNull() {
+		if data.MaxRetries.ValueInt64() < 0 {
+			resp.Diagnostics.AddError(
+				"invalid max_retries",
+				"max_retries must not be negative.",
+			)
+			return
+		}
+		maxRetries = int(data.MaxRetries.ValueInt64())
+	}
+
+	retryMaxWait := shieldoo.DefaultRetryMaxWait
+	if !data.RetryMaxWait.IsNull() {
+		if data.RetryMaxWait.ValueInt64() < 1 {
+			resp.Diagnostics.AddError(
+				"invalid retry_max_wait",
+				"retry_max_wait must be at least 1 second.",
+			)
+			return
+		}
+		retryMaxWait = time.Duration(data.RetryMaxWait.ValueInt64()) * time.Second
+	}
+	opts = append(opts, shieldoo.WithRetries(maxRetries, retryMaxWait))
+
+	instance := os.Getenv("SHIELDOO_INSTANCE")
+	if data.Instance.ValueString() != "" {
+		instance = data.Instance.ValueString()
+	}
+	if instance != "" {
+		opts = append(opts, shieldoo.WithInstance(instance))
+	}
+
+	if !data.MaxRequestsPerSecond.IsNull() {
+		if data.MaxRequestsPerSecond.ValueFloat64() <= 0 {
+			resp.Diagnostics.AddError(
+				"invalid max_requests_per_second",
+				"max_requests_per_second must be greater than 0.",
+			)
+			return
+		}
+		opts = append(opts, shieldoo.WithRateLimit(data.MaxRequestsPerSecond.ValueFloat64()))
+	}
+
+	if !data.MaxConcurrentRequests.IsNull() {
+		if data.MaxConcurrentRequests.ValueInt64() < 1 {
+			resp.Diagnostics.AddError(
+				"invalid max_concurrent_requests",
+				"max_concurrent_requests must be at least 1.",
+			)
+			return
+		}
+		opts = append(opts, shieldoo.WithMaxConcurrentRequests(int(data.MaxConcurrentRequests.ValueInt64())))
+	}
+
+	cacheTTL := defaultCacheTTL
+	if !data.CacheTTL.IsNull() {
+		if data.CacheTTL.ValueInt64() < 0 {
+			resp.Diagnostics.AddError(
+				"invalid cache_ttl",
+				"cache_ttl must not be negative.",
+			)
+			return
+		}
+		cacheTTL = time.Duration(data.CacheTTL.ValueInt64()) * time.Second
+	}
+	opts = append(opts, shieldoo.WithCache(cacheTTL))
+
+	if !data.TokenLifetime.IsNull() {
+		if data.TokenLifetime.ValueInt64() < 10 {
+			resp.Diagnostics.AddError(
+				"invalid token_lifetime",
+				"token_lifetime must be at least 10 seconds.",
+			)
+			return
+		}
+		opts = append(opts, shieldoo.WithTokenLifetime(time.Duration(data.TokenLifetime.ValueInt64())*time.Second))
+	}
+
+	if !data.TokenClockSkew.IsNull() {
+		if data.TokenClockSkew.ValueInt64() < 0 {
+			resp.Diagnostics.AddError(
+				"invalid token_clock_skew",
+				"token_clock_skew must not be negative.",
+			)
+			return
+		}
+		opts = append(opts, shieldoo.WithTokenClockSkew(time.Duration(data.TokenClockSkew.ValueInt64())*time.Second))
+	}
+
+	if !data.TokenClaims.IsNull() {
+		tokenClaims := map[string]string{}
+		resp.Diagnostics.Append(data.TokenClaims.ElementsAs(ctx, &tokenClaims, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		if _, ok := tokenClaims["instance"]; ok {
+			resp.Diagnostics.AddError(
+				"invalid token_claims",
+				"The instance claim cannot be set in token_claims, set the instance attribute instead.",
+			)
+			return
+		}
+		opts = append(opts, shieldoo.WithTokenClaims(tokenClaims))
+	}
+
+	transport := shieldoo.TransportConfig{
+		CACertFile:         data.CACertFile.ValueString(),
+		CACertPEM:          data.CACertPEM.ValueString(),
+		ClientCertFile:     data.ClientCertFile.ValueString(),
+		ClientKeyFile:      data.ClientKeyFile.ValueString(),
+		ClientCertPEM:      data.ClientCertPEM.ValueString(),
+		ClientKeyPEM:       data.ClientKeyPEM.ValueString(),
+		ProxyURL:           data.ProxyURL.ValueString(),
+		InsecureSkipVerify: data.InsecureSkipVerify.ValueBool(),
+	}
+	if !data.RequestTimeout.IsNull() {
+		if data.RequestTimeout.ValueInt64() < 1 {
+			resp.Diagnostics.AddError(
+				"invalid request_timeout",
+				"request_timeout must be at least 1 second.",
+			)
+			return
+		}
+		transport.RequestTimeout = time.Duration(data.RequestTimeout.ValueInt64()) * time.Second
+	}
+
+	httpClient, err := shieldoo.NewHTTPClient(transport)
+	if err != nil {
 		resp.Diagnostics.AddError(
-			"apikey is required",
-			"Please set the apikey in the provider configuration block.",
+			"invalid HTTP transport configuration",
+			err.Error(),
 		)
 		return
 	}
+	opts = append(opts, shieldoo.WithHTTPClient(httpClient))
 
 	// Example client configuration for data sources and resources
-	client := &ShieldooClient{
-		uri:    endpoint,
-		apiKey: apiKey,
+	client, err := shieldoo.NewClient(endpoint, opts...)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"invalid provider configuration",
+			err.Error(),
+		)
+		return
+	}
+
+	if !data.SkipCredentialsValidation.ValueBool() {
+		// any authenticated call checks the endpoint and the credentials, listing
+		// groups has no side effects
+		_, err := client.ListGroups(ctx)
+		warnSecondaryAPIKey(client, &resp.Diagnostics)
+		if err != nil {
+			summary, detail := credentialsErrorDiagnostic(client.Instance(), err)
+			resp.Diagnostics.AddError(summary, detail)
+			return
+		}
 	}
 	resp.DataSourceData = client
 	resp.ResourceData = client
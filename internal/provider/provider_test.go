@@ -1,10 +1,16 @@
 package provider
 
 import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 )
 
 // testAccProtoV6ProviderFactories are used to instantiate a provider during
@@ -20,3 +26,234 @@ func testAccPreCheck(t *testing.T) {
 	// about the appropriate environment variables being set are common to see in a pre-check
 	// function.
 }
+
+func TestAccProviderClientCredentials(t *testing.T) {
+	fake := newTestAccFakeServer(t)
+	config := func(clientSecret string) string {
+		return fmt.Sprintf(`
+provider "shieldoo" {
+  endpoint = %[1]q
+
+  auth {
+    client_credentials {
+      token_url     = "%[1]s%[2]s"
+      client_id     = %[3]q
+      client_secret = %[4]q
+    }
+  }
+}
+`, fake.URL, testAccFakeTokenPath, testAccFakeClientID, clientSecret) + testAccFirewallResourceConfig("one", "22")
+	}
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             fake.checkFirewallsDestroyed,
+		Steps: []resource.TestStep{
+			// A rejected client is reported by the credentials validation
+			{
+				Config:      config("wrong"),
+				ExpectError: regexp.MustCompile("Unable to obtain a Shieldoo access token"),
+			},
+			// The API is called with the bearer token instead of the API key
+			{
+				Config: config(testAccFakeClientSecret),
+				Check:  fake.checkFirewall("one", "22"),
+			},
+		},
+	})
+}
+
+func TestAccProviderWorkloadIdentity(t *testing.T) {
+	fake := newTestAccFakeServer(t)
+	tokenFile := filepath.Join(t.TempDir(), "oidc-token")
+	if err := os.WriteFile(tokenFile, []byte(testAccFakeOIDCToken), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf(`
+provider "shieldoo" {
+  endpoint = %q
+
+  auth {
+    workload_identity {
+      token_file = %q
+    }
+  }
+}
+`, fake.URL, tokenFile) + testAccFirewallResourceConfig("one", "22")
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             fake.checkFirewallsDestroyed,
+		Steps: []resource.TestStep{
+			// Only one authentication method may be configured
+			{
+				Config: fmt.Sprintf(`
+provider "shieldoo" {
+  endpoint = %q
+
+  auth {
+    client_credentials {
+      token_url     = "https://idp.example.com/token"
+      client_id     = "terraform"
+      client_secret = "secret"
+    }
+    workload_identity {
+      token_file = %q
+    }
+  }
+}
+`, fake.URL, tokenFile) + testAccFirewallResourceConfig("one", "22"),
+				ExpectError: regexp.MustCompile("Only one of client_credentials and workload_identity"),
+			},
+			// The OIDC token is exchanged at the default exchange URL of the endpoint
+			{
+				Config: config,
+				Check:  fake.checkFirewall("one", "22"),
+			},
+		},
+	})
+}
+
+func TestAccProviderCredentialSources(t *testing.T) {
+	fake := newTestAccFakeServer(t)
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(credentialsFile, []byte(fmt.Sprintf("[fake]\nendpoint = %s\napikey = %s\n", fake.URL, testAccFakeApiKey)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	apiKeyFile := filepath.Join(dir, "apikey")
+	if err := os.WriteFile(apiKeyFile, []byte(testAccFakeApiKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the profile overrides the environment, the provider block overrides both
+	t.Setenv("SHIELDOO_ENDPOINT", "https://wrong.shieldoo.invalid")
+	t.Setenv("SHIELDOO_API_KEY", "wrong")
+	t.Setenv("SHIELDOO_CREDENTIALS_FILE", credentialsFile)
+
+	firewall := testAccFirewallResourceConfig("one", "22")
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             fake.checkFirewallsDestroyed,
+		Steps: []resource.TestStep{
+			// The profile replaces the endpoint and API key of the environment
+			{
+				Config: `
+provider "shieldoo" {
+  profile = "fake"
+}
+` + firewall,
+				Check: fake.checkFirewall("one", "22"),
+			},
+			// The apikey of the provider block replaces the one of the profile
+			{
+				Config: `
+provider "shieldoo" {
+  profile = "fake"
+  apikey  = "wrong"
+}
+` + firewall,
+				ExpectError: regexp.MustCompile("Invalid Shieldoo API key"),
+			},
+			// Only one API key source may be configured
+			{
+				Config: fmt.Sprintf(`
+provider "shieldoo" {
+  endpoint    = %q
+  apikey      = %q
+  apikey_file = %q
+}
+`, fake.URL, testAccFakeApiKey, apiKeyFile) + firewall,
+				ExpectError: regexp.MustCompile("Only one of apikey, apikey_file and apikey_command"),
+			},
+			// The API key is read from apikey_file
+			{
+				Config: fmt.Sprintf(`
+provider "shieldoo" {
+  endpoint    = %q
+  apikey_file = %q
+}
+`, fake.URL, apiKeyFile) + firewall,
+				Check: fake.checkFirewall("one", "22"),
+			},
+			// The API key is printed by apikey_command
+			{
+				Config: fmt.Sprintf(`
+provider "shieldoo" {
+  endpoint       = %q
+  apikey_command = "echo %s"
+}
+`, fake.URL, testAccFakeApiKey) + firewall,
+				Check: fake.checkFirewall("one", "22"),
+			},
+		},
+	})
+}
+
+func TestAccProviderSkipCredentialsValidation(t *testing.T) {
+	fake := newTestAccFakeServer(t)
+	config := func(skip bool) string {
+		return fmt.Sprintf(`
+provider "shieldoo" {
+  endpoint                    = %q
+  apikey                      = "wrong"
+  skip_credentials_validation = %t
+}
+`, fake.URL, skip) + testAccFirewallResourceConfig("one", "22")
+	}
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The rejected API key is reported while the provider is configured
+			{
+				Config:      config(false),
+				ExpectError: regexp.MustCompile("Invalid Shieldoo API key"),
+			},
+			// Without the validation planning a create makes no API call
+			{
+				Config:             config(true),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestAccProviderInstance(t *testing.T) {
+	fake := newTestAccFakeServer(t)
+	u, err := url.Parse(fake.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := func(settings string) string {
+		return fmt.Sprintf(`
+provider "shieldoo" {
+  endpoint = %q
+  apikey   = %q
+  %s
+}
+`, fake.URL, testAccFakeApiKey, settings) + testAccFirewallResourceConfig("one", "22")
+	}
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             fake.checkFirewallsDestroyed,
+		Steps: []resource.TestStep{
+			// The instance claim is set with the instance attribute only
+			{
+				Config:      config(`token_claims = { instance = "other.shieldoo.net" }`),
+				ExpectError: regexp.MustCompile("invalid token_claims"),
+			},
+			// The fake API expects the endpoint hostname as instance
+			{
+				Config:      config(`instance = "other.shieldoo.net"`),
+				ExpectError: regexp.MustCompile("Shieldoo API rejected the instance claim"),
+			},
+			{
+				Config: config(fmt.Sprintf(`instance = %q`, u.Hostname())),
+				Check:  fake.checkFirewall("one", "22"),
+			},
+		},
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
@@ -19,7 +20,7 @@ func NewServerDataSource() datasource.DataSource {
 
 // ServerDataSource defines the data source implementation.
 type ServerDataSource struct {
-	client *ShieldooClient
+	client *shieldoo.Client
 }
 
 // ServerDataSourceModel describes the data source data model.
@@ -67,7 +68,7 @@ func (d *ServerDataSource) Configure(ctx context.Context, req datasource.Configu
 		return
 	}
 
-	client, ok := req.ProviderData.(*ShieldooClient)
+	client, ok := req.ProviderData.(*shieldoo.Client)
 
 	if !ok {
 		resp.Diagnostics.AddError(
@@ -91,7 +92,8 @@ func (d *ServerDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 		return
 	}
 
-	server, err := d.client.GetServer(data.Name.ValueString())
+	server, err := d.client.GetServer(ctx, data.Name.ValueString())
+	warnSecondaryAPIKey(d.client, &resp.Diagnostics)
 	if err != nil {
 		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("ERROR: %s", err.Error()))
 		tflog.Error(ctx, "Client Error", map[string]interface{}{"err": err.Error()})
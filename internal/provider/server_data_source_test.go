@@ -1,21 +1,35 @@
 package provider
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
 )
 
 func TestAccServerDataSource(t *testing.T) {
+	fake := newTestAccFakeServer(t)
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
 			// Read testing
 			{
-				Config: testAccServerDataSourceConfig,
+				Config: fake.ProviderConfig() + testAccServerDataSourceConfig,
 				Check: resource.ComposeAggregateTestCheckFunc(
-					resource.TestCheckResourceAttr("data.shieldoo_server.test", "id", "mockup"),
+					resource.TestCheckResourceAttrPair("data.shieldoo_server.test", "id", "shieldoo_server.test", "id"),
+					resource.TestCheckResourceAttrSet("data.shieldoo_server.test", "ip_address"),
+					resource.TestCheckResourceAttrSet("data.shieldoo_server.test", "configuration"),
 				),
 			},
 		},
@@ -23,11 +37,53 @@ func TestAccServerDataSource(t *testing.T) {
 }
 
 const testAccServerDataSourceConfig = `
-provider "shieldoo" {
-	endpoint = "https://mockup"
-	apikey = "mockup"
+resource "shieldoo_firewall" "test" {
+  name = "example"
+}
+resource "shieldoo_server" "test" {
+  name        = "example"
+  firewall_id = shieldoo_firewall.test.id
 }
 data "shieldoo_server" "test" {
   name = "example"
+  depends_on = [shieldoo_server.test]
 }
 `
+
+func TestServerDataSourceWarnsAboutSecondaryAPIKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := jwt.Parse(r.Header.Get("AuthToken"), func(*jwt.Token) (interface{}, error) { return []byte("new-key"), nil }); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"1","name":"web"}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := shieldoo.NewClient(srv.URL, shieldoo.WithAPIKey("old-key"), shieldoo.WithSecondaryAPIKey("new-key"), shieldoo.WithRetries(0, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &ServerDataSource{client: client}
+	schemaResp := &datasource.SchemaResponse{}
+	d.Schema(ctx, datasource.SchemaRequest{}, schemaResp)
+	read := func() diag.Diagnostics {
+		plan := tfsdk.Plan{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+		if diags := plan.Set(ctx, &ServerDataSourceModel{Name: types.StringValue("web")}); diags.HasError() {
+			t.Fatal(diags)
+		}
+		config := tfsdk.Config{Schema: plan.Schema, Raw: plan.Raw}
+		resp := &datasource.ReadResponse{State: tfsdk.State{Schema: schemaResp.Schema, Raw: config.Raw}}
+		d.Read(ctx, datasource.ReadRequest{Config: config}, resp)
+		return resp.Diagnostics
+	}
+
+	// the primary key is first rejected during Read, e.g. with skip_credentials_validation
+	if diags := read(); diags.HasError() || diags.WarningsCount() != 1 || diags.Warnings()[0].Summary() != "Using the secondary Shieldoo API key" {
+		t.Fatalf("expected the secondary key warning, got %v", diags)
+	}
+	if diags := read(); diags.HasError() || diags.WarningsCount() != 0 {
+		t.Fatalf("expected the warning only once, got %v", diags)
+	}
+}
@@ -4,20 +4,26 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &ServerResource{}
 var _ resource.ResourceWithImportState = &ServerResource{}
+var _ resource.ResourceWithValidateConfig = &ServerResource{}
 
 func NewServerResource() resource.Resource {
 	return &ServerResource{}
@@ -25,7 +31,7 @@ func NewServerResource() resource.Resource {
 
 // ServerResource defines the resource implementation.
 type ServerResource struct {
-	client *ShieldooClient
+	client *shieldoo.Client
 }
 
 // ServerResourceModel describes the resource data model.
@@ -48,6 +54,15 @@ type ServerResourceModel struct {
 	OSUpdateHour            types.Int64                      `tfsdk:"os_update_hour"`
 }
 
+// serverListenerAttrTypes are the attribute types of a listeners element.
+var serverListenerAttrTypes = map[string]attr.Type{
+	"listen_port":  types.Int64Type,
+	"protocol":     types.StringType,
+	"forward_port": types.Int64Type,
+	"forward_host": types.StringType,
+	"description":  types.StringType,
+}
+
 type ServerResourceModelListenerType struct {
 	types.ListType
 }
@@ -67,8 +82,8 @@ type ServerResourceModelListenerValue struct {
 	types.List
 }
 
-func (c ServerResourceModelListenerValue) ParseServerListenersFromModel(ctx context.Context) []Listener {
-	var listeners []Listener
+func (c ServerResourceModelListenerValue) ParseServerListenersFromModel(ctx context.Context) []shieldoo.Listener {
+	var listeners []shieldoo.Listener
 	for _, listener := range c.Elements() {
 		listener, ok := listener.(types.Object)
 		if !ok {
@@ -101,7 +116,7 @@ func (c ServerResourceModelListenerValue) ParseServerListenersFromModel(ctx cont
 			continue
 		}
 
-		r := Listener{
+		r := shieldoo.Listener{
 			// unchecked type assertion
 			ListenPort:  int(listenport.ValueInt64()),
 			Protocol:    protocol.ValueString(),
@@ -114,6 +129,40 @@ func (c ServerResourceModelListenerValue) ParseServerListenersFromModel(ctx cont
 	return listeners
 }
 
+// ServerListenersToModel converts the listeners returned by the API, an empty
+// description stays null where prior had it null.
+func ServerListenersToModel(listeners []shieldoo.Listener, prior ServerResourceModelListenerValue) (ServerResourceModelListenerValue, diag.Diagnostics) {
+	elemType := types.ObjectType{AttrTypes: serverListenerAttrTypes}
+	if len(listeners) == 0 && prior.IsNull() {
+		return ServerResourceModelListenerValue{types.ListNull(elemType)}, nil
+	}
+	var diags diag.Diagnostics
+	priorElements := prior.Elements()
+	elements := []attr.Value{}
+	for i, l := range listeners {
+		priorDescription := types.StringNull()
+		if i < len(priorElements) {
+			if o, ok := priorElements[i].(types.Object); ok {
+				if d, ok := o.Attributes()["description"].(types.String); ok {
+					priorDescription = d
+				}
+			}
+		}
+		o, d := types.ObjectValue(serverListenerAttrTypes, map[string]attr.Value{
+			"listen_port":  types.Int64Value(int64(l.ListenPort)),
+			"protocol":     types.StringValue(l.Protocol),
+			"forward_port": types.Int64Value(int64(l.ForwardPort)),
+			"forward_host": types.StringValue(l.ForwardHost),
+			"description":  optionalString(l.Description, priorDescription),
+		})
+		diags.Append(d...)
+		elements = append(elements, o)
+	}
+	list, d := types.ListValue(elemType, elements)
+	diags.Append(d...)
+	return ServerResourceModelListenerValue{list}, diags
+}
+
 func (r *ServerResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_server"
 }
@@ -163,6 +212,10 @@ func (r *ServerResource) Schema(ctx context.Context, req resource.SchemaRequest,
 			"ip_address": schema.StringAttribute{
 				MarkdownDescription: "IP Address (if omitted, will be assigned automatically)",
 				Optional:            true,
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
 			},
 			"group_ids": schema.ListAttribute{
 				MarkdownDescription: "Group IDs",
@@ -184,34 +237,38 @@ func (r *ServerResource) Schema(ctx context.Context, req resource.SchemaRequest,
 				MarkdownDescription: "Server listeners",
 				CustomType: ServerResourceModelListenerType{
 					types.ListType{
-						ElemType: types.ObjectType{
-							AttrTypes: map[string]attr.Type{
-								"listen_port":  types.Int64Type,
-								"protocol":     types.StringType,
-								"forward_port": types.Int64Type,
-								"forward_host": types.StringType,
-								"description":  types.StringType,
-							},
-						},
+						ElemType: types.ObjectType{AttrTypes: serverListenerAttrTypes},
 					},
 				},
 				NestedObject: schema.NestedAttributeObject{
 					Attributes: map[string]schema.Attribute{
 						"listen_port": schema.Int64Attribute{
 							Required:            true,
-							MarkdownDescription: "Listen port",
+							MarkdownDescription: "Listen port (1-65535)",
+							Validators: []validator.Int64{
+								int64validator.Between(1, 65535),
+							},
 						},
 						"protocol": schema.StringAttribute{
 							Required:            true,
-							MarkdownDescription: "Protocol",
+							MarkdownDescription: "Protocol (`tcp` or `udp`)",
+							Validators: []validator.String{
+								stringvalidator.OneOf("tcp", "udp"),
+							},
 						},
 						"forward_port": schema.Int64Attribute{
 							Required:            true,
-							MarkdownDescription: "Forward port",
+							MarkdownDescription: "Forward port (1-65535)",
+							Validators: []validator.Int64{
+								int64validator.Between(1, 65535),
+							},
 						},
 						"forward_host": schema.StringAttribute{
 							Required:            true,
-							MarkdownDescription: "Forward host",
+							MarkdownDescription: "Forward host (IP address or host name)",
+							Validators: []validator.String{
+								hostnameOrIP(),
+							},
 						},
 						"description": schema.StringAttribute{
 							Optional:            true,
@@ -245,7 +302,7 @@ func (r *ServerResource) Configure(ctx context.Context, req resource.ConfigureRe
 		return
 	}
 
-	client, ok := req.ProviderData.(*ShieldooClient)
+	client, ok := req.ProviderData.(*shieldoo.Client)
 
 	if !ok {
 		resp.Diagnostics.AddError(
@@ -259,7 +316,43 @@ func (r *ServerResource) Configure(ctx context.Context, req resource.ConfigureRe
 	r.client = client
 }
 
-func (r *ServerResource) NormalizeServerListener(listener *Listener) error {
+// ValidateConfig rejects listeners using the same listen_port and protocol.
+func (r *ServerResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data ServerResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	listeners := data.Listeners
+	if resp.Diagnostics.HasError() || listeners.IsNull() || listeners.IsUnknown() {
+		return
+	}
+
+	seen := map[string]int{}
+	for i, listener := range listeners.Elements() {
+		listener, ok := listener.(types.Object)
+		if !ok || listener.IsNull() || listener.IsUnknown() {
+			continue
+		}
+		listenPort, ok := listener.Attributes()["listen_port"].(types.Int64)
+		if !ok || listenPort.IsNull() || listenPort.IsUnknown() {
+			continue
+		}
+		protocol, ok := listener.Attributes()["protocol"].(types.String)
+		if !ok || protocol.IsNull() || protocol.IsUnknown() {
+			continue
+		}
+		key := fmt.Sprintf("%d/%s", listenPort.ValueInt64(), protocol.ValueString())
+		if first, ok := seen[key]; ok {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("listeners").AtListIndex(i).AtName("listen_port"),
+				"Duplicate listener",
+				fmt.Sprintf("Listener %d uses listen_port %d with protocol %s, which is already used by listener %d.", i, listenPort.ValueInt64(), protocol.ValueString(), first),
+			)
+			continue
+		}
+		seen[key] = i
+	}
+}
+
+func (r *ServerResource) NormalizeServerListener(listener *shieldoo.Listener) error {
 	if listener.ListenPort < 1 || listener.ListenPort > 65535 {
 		return fmt.Errorf("listen_port must be between 1 and 65535")
 	}
@@ -272,7 +365,7 @@ func (r *ServerResource) NormalizeServerListener(listener *Listener) error {
 	return nil
 }
 
-func (r *ServerResource) NormalizeServer(server *Server) error {
+func (r *ServerResource) NormalizeServer(server *shieldoo.Server) error {
 	for i := range server.Listeners {
 		if err := r.NormalizeServerListener(&server.Listeners[i]); err != nil {
 			return err
@@ -291,14 +384,14 @@ func (r *ServerResource) Create(ctx context.Context, req resource.CreateRequest,
 		return
 	}
 
-	server := &Server{
+	server := &shieldoo.Server{
 		Name:        data.Name.ValueString(),
 		Autoupdate:  data.Autoupdate.ValueBool(),
 		Description: data.Description.ValueString(),
 		IpAddress:   data.IpAddress.ValueString(),
-		Firewall:    Firewall{Id: data.FirewallId.ValueString()},
+		Firewall:    shieldoo.Firewall{Id: data.FirewallId.ValueString()},
 		Listeners:   data.Listeners.ParseServerListenersFromModel(ctx),
-		OSUpdatePolicy: ServerOSAutoupdatePolicy{
+		OSUpdatePolicy: shieldoo.ServerOSAutoupdatePolicy{
 			Enabled:                   data.OSUpdateEnabled.ValueBool(),
 			SecurityAutoupdateEnabled: data.OSSecurityUpdateEnabled.ValueBool(),
 			AllAutoupdateEnabled:      data.OSAllUpdateEnabled.ValueBool(),
@@ -315,7 +408,7 @@ func (r *ServerResource) Create(ctx context.Context, req resource.CreateRequest,
 				tflog.Error(ctx, "error parsing group id")
 				return
 			}
-			server.Groups = append(server.Groups, Group{Id: g.ValueString()})
+			server.Groups = append(server.Groups, shieldoo.Group{Id: g.ValueString()})
 		}
 	}
 
@@ -327,7 +420,7 @@ func (r *ServerResource) Create(ctx context.Context, req resource.CreateRequest,
 				tflog.Error(ctx, "error parsing group name")
 				return
 			}
-			server.Groups = append(server.Groups, Group{Name: g.ValueString()})
+			server.Groups = append(server.Groups, shieldoo.Group{Name: g.ValueString()})
 		}
 	}
 
@@ -339,7 +432,7 @@ func (r *ServerResource) Create(ctx context.Context, req resource.CreateRequest,
 				tflog.Error(ctx, "error parsing group object id")
 				return
 			}
-			server.Groups = append(server.Groups, Group{ObjectId: g.ValueString()})
+			server.Groups = append(server.Groups, shieldoo.Group{ObjectId: g.ValueString()})
 		}
 	}
 
@@ -349,7 +442,8 @@ func (r *ServerResource) Create(ctx context.Context, req resource.CreateRequest,
 		return
 	}
 
-	Server, err := r.client.CreateServer(server)
+	Server, err := r.client.CreateServer(ctx, server)
+	warnSecondaryAPIKey(r.client, &resp.Diagnostics)
 	if err != nil {
 		resp.Diagnostics.AddError("Error creating Server", err.Error())
 		tflog.Error(ctx, "error creating Server", map[string]interface{}{"error": err.Error()})
@@ -360,6 +454,7 @@ func (r *ServerResource) Create(ctx context.Context, req resource.CreateRequest,
 	// save into the Terraform state.
 	data.Id = types.StringValue(Server.Id)
 	data.Configuration = types.StringValue(Server.Configuration)
+	data.IpAddress = types.StringValue(Server.IpAddress)
 	tflog.Trace(ctx, "created a resource")
 
 	// Save data into Terraform state
@@ -376,7 +471,13 @@ func (r *ServerResource) Read(ctx context.Context, req resource.ReadRequest, res
 		return
 	}
 
-	server, err := r.client.GetServer(data.Name.ValueString())
+	server, err := r.client.GetServerByID(ctx, data.Id.ValueString())
+	warnSecondaryAPIKey(r.client, &resp.Diagnostics)
+	if shieldoo.IsNotFound(err) {
+		tflog.Warn(ctx, "Server not found, removing from state", map[string]interface{}{"id": data.Id.ValueString()})
+		resp.State.RemoveResource(ctx)
+		return
+	}
 	if err != nil {
 		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to get Server, got error: %s", err))
 		tflog.Error(ctx, "Client Error", map[string]interface{}{"err": err.Error()})
@@ -384,7 +485,30 @@ func (r *ServerResource) Read(ctx context.Context, req resource.ReadRequest, res
 	}
 
 	data.Id = types.StringValue(server.Id)
+	data.Name = types.StringValue(server.Name)
 	data.Configuration = types.StringValue(server.Configuration)
+	data.Description = optionalString(server.Description, data.Description)
+	data.IpAddress = types.StringValue(server.IpAddress)
+	data.FirewallId = types.StringValue(server.Firewall.Id)
+	data.Autoupdate = optionalBool(server.Autoupdate, data.Autoupdate)
+	data.OSUpdateEnabled = optionalBool(server.OSUpdatePolicy.Enabled, data.OSUpdateEnabled)
+	data.OSSecurityUpdateEnabled = optionalBool(server.OSUpdatePolicy.SecurityAutoupdateEnabled, data.OSSecurityUpdateEnabled)
+	data.OSAllUpdateEnabled = optionalBool(server.OSUpdatePolicy.AllAutoupdateEnabled, data.OSAllUpdateEnabled)
+	data.OSRestartAfterUpdate = optionalBool(server.OSUpdatePolicy.RestartAfterUpdate, data.OSRestartAfterUpdate)
+	data.OSUpdateHour = optionalInt64(int64(server.OSUpdatePolicy.UpdateHour), data.OSUpdateHour)
+
+	groups, diags := renderGroupRefs(ctx, server.Groups, groupRefs{Ids: data.GroupIds, ObjectIds: data.GroupObjectIds, Names: data.GroupNames})
+	resp.Diagnostics.Append(diags...)
+	data.GroupIds = groups.Ids
+	data.GroupObjectIds = groups.ObjectIds
+	data.GroupNames = groups.Names
+
+	listeners, diags := ServerListenersToModel(server.Listeners, data.Listeners)
+	resp.Diagnostics.Append(diags...)
+	data.Listeners = listeners
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
@@ -400,15 +524,15 @@ func (r *ServerResource) Update(ctx context.Context, req resource.UpdateRequest,
 		return
 	}
 
-	server := &Server{
+	server := &shieldoo.Server{
 		Id:          data.Id.ValueString(),
 		Autoupdate:  data.Autoupdate.ValueBool(),
 		Name:        data.Name.ValueString(),
 		Description: data.Description.ValueString(),
 		IpAddress:   data.IpAddress.ValueString(),
-		Firewall:    Firewall{Id: data.FirewallId.ValueString()},
+		Firewall:    shieldoo.Firewall{Id: data.FirewallId.ValueString()},
 		Listeners:   data.Listeners.ParseServerListenersFromModel(ctx),
-		OSUpdatePolicy: ServerOSAutoupdatePolicy{
+		OSUpdatePolicy: shieldoo.ServerOSAutoupdatePolicy{
 			Enabled:                   data.OSUpdateEnabled.ValueBool(),
 			SecurityAutoupdateEnabled: data.OSSecurityUpdateEnabled.ValueBool(),
 			AllAutoupdateEnabled:      data.OSAllUpdateEnabled.ValueBool(),
@@ -425,7 +549,7 @@ func (r *ServerResource) Update(ctx context.Context, req resource.UpdateRequest,
 				tflog.Error(ctx, "error parsing group id")
 				return
 			}
-			server.Groups = append(server.Groups, Group{Id: g.ValueString()})
+			server.Groups = append(server.Groups, shieldoo.Group{Id: g.ValueString()})
 		}
 	}
 
@@ -437,7 +561,7 @@ func (r *ServerResource) Update(ctx context.Context, req resource.UpdateRequest,
 				tflog.Error(ctx, "error parsing group name")
 				return
 			}
-			server.Groups = append(server.Groups, Group{Name: g.ValueString()})
+			server.Groups = append(server.Groups, shieldoo.Group{Name: g.ValueString()})
 		}
 	}
 
@@ -449,7 +573,7 @@ func (r *ServerResource) Update(ctx context.Context, req resource.UpdateRequest,
 				tflog.Error(ctx, "error parsing group object id")
 				return
 			}
-			server.Groups = append(server.Groups, Group{ObjectId: g.ValueString()})
+			server.Groups = append(server.Groups, shieldoo.Group{ObjectId: g.ValueString()})
 		}
 	}
 
@@ -459,7 +583,8 @@ func (r *ServerResource) Update(ctx context.Context, req resource.UpdateRequest,
 		return
 	}
 
-	server, err := r.client.UpdateServer(server)
+	server, err := r.client.UpdateServer(ctx, server)
+	warnSecondaryAPIKey(r.client, &resp.Diagnostics)
 	if err != nil {
 		resp.Diagnostics.AddError("Error updating Server", err.Error())
 		tflog.Error(ctx, "error updating Server", map[string]interface{}{"error": err.Error()})
@@ -467,6 +592,7 @@ func (r *ServerResource) Update(ctx context.Context, req resource.UpdateRequest,
 	}
 
 	data.Configuration = types.StringValue(server.Configuration)
+	data.IpAddress = types.StringValue(server.IpAddress)
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
@@ -481,7 +607,8 @@ func (r *ServerResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		return
 	}
 
-	err := r.client.DeleteServer(data.Id.ValueString())
+	err := r.client.DeleteServer(ctx, data.Id.ValueString())
+	warnSecondaryAPIKey(r.client, &resp.Diagnostics)
 	if err != nil {
 		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to delete Server, got error: %s", err))
 		tflog.Error(ctx, "Client Error", map[string]interface{}{"err": err.Error()})
@@ -490,5 +617,21 @@ func (r *ServerResource) Delete(ctx context.Context, req resource.DeleteRequest,
 }
 
 func (r *ServerResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	byName, value, err := parseImportID(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid import ID", err.Error())
+		return
+	}
+	if byName {
+		server, err := r.client.GetServer(ctx, value)
+		warnSecondaryAPIKey(r.client, &resp.Diagnostics)
+		if err != nil {
+			resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to find Server %q, got error: %s", value, err))
+			tflog.Error(ctx, "Client Error", map[string]interface{}{"err": err.Error()})
+			return
+		}
+		value = server.Id
+	}
+	// Read populates the rest of the attributes
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), value)...)
 }
@@ -0,0 +1,187 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	fwresource "github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
+)
+
+func TestAccServerResource(t *testing.T) {
+	fake := newTestAccFakeServer(t)
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             fake.checkServersDestroyed,
+		Steps: []resource.TestStep{
+			// Create and Read testing
+			{
+				Config: fake.ProviderConfig() + testAccServerResourceConfig("one", 80),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("shieldoo_server.test", "id"),
+					resource.TestCheckResourceAttrSet("shieldoo_server.test", "configuration"),
+					resource.TestCheckResourceAttr("shieldoo_server.test", "name", "one"),
+					fake.checkServer("one", 80),
+				),
+			},
+			// ImportState testing
+			{
+				ResourceName:      "shieldoo_server.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			// Update and Read testing
+			{
+				Config: fake.ProviderConfig() + testAccServerResourceConfig("one", 8080),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("shieldoo_server.test", "id"),
+					fake.checkServer("one", 8080),
+				),
+			},
+			// Import by name
+			{
+				ResourceName:      "shieldoo_server.test",
+				ImportState:       true,
+				ImportStateId:     "name:one",
+				ImportStateVerify: true,
+			},
+			// Drift made outside of Terraform is detected and reverted
+			{
+				PreConfig: func() { fake.driftServer(t, "one") },
+				Config:    fake.ProviderConfig() + testAccServerResourceConfig("one", 8080),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("shieldoo_server.test", "description", "test server"),
+					resource.TestCheckNoResourceAttr("shieldoo_server.test", "autoupdate"),
+					fake.checkServer("one", 8080),
+				),
+			},
+			// Rename and Read testing
+			{
+				Config: fake.ProviderConfig() + testAccServerResourceConfig("two", 8080),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("shieldoo_server.test", "name", "two"),
+					fake.checkServer("two", 8080),
+				),
+			},
+			// Delete testing automatically occurs in TestCase
+		},
+	})
+}
+
+func testAccServerResourceConfig(name string, port int) string {
+	return fmt.Sprintf(`
+resource "shieldoo_firewall" "test" {
+  name = "server-firewall"
+}
+resource "shieldoo_server" "test" {
+  name        = %[1]q
+  description = "test server"
+  firewall_id = shieldoo_firewall.test.id
+  group_ids   = ["group-developers"]
+  listeners = [
+    {
+      listen_port  = %[2]d
+      protocol     = "tcp"
+      forward_port = %[2]d
+      forward_host = "127.0.0.1"
+      description  = "web"
+    },
+  ]
+}
+`, name, port)
+}
+
+func (f *testAccFakeServer) checkServer(name string, port int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		srv, err := f.client.GetServer(context.Background(), name)
+		if err != nil {
+			return fmt.Errorf("server %s not found on the server: %w", name, err)
+		}
+		if len(srv.Listeners) != 1 || srv.Listeners[0].ListenPort != port {
+			return fmt.Errorf("unexpected listeners of server %s: %+v", name, srv.Listeners)
+		}
+		if len(srv.Groups) != 1 || srv.Groups[0].Name != "developers" {
+			return fmt.Errorf("unexpected groups of server %s: %+v", name, srv.Groups)
+		}
+		return nil
+	}
+}
+
+// driftServer changes the server the way an administrator would in the UI.
+func (f *testAccFakeServer) driftServer(t *testing.T, name string) {
+	ctx := context.Background()
+	srv, err := f.client.GetServer(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Description = "changed in UI"
+	srv.Autoupdate = true
+	srv.Listeners[0].ListenPort = 9999
+	srv.Groups = append(srv.Groups, shieldoo.Group{Id: "group-admins"})
+	if _, err := f.client.UpdateServer(ctx, srv); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *testAccFakeServer) checkServersDestroyed(s *terraform.State) error {
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "shieldoo_server" {
+			continue
+		}
+		_, err := f.client.GetServerByID(context.Background(), rs.Primary.ID)
+		if err == nil {
+			return fmt.Errorf("server %s still exists", rs.Primary.ID)
+		}
+		if !shieldoo.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestServerResourceValidateConfigDuplicateListeners(t *testing.T) {
+	ctx := context.Background()
+	r := &ServerResource{}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	listeners, diags := ServerListenersToModel([]shieldoo.Listener{
+		{ListenPort: 80, Protocol: "tcp", ForwardPort: 80, ForwardHost: "127.0.0.1"},
+		{ListenPort: 80, Protocol: "udp", ForwardPort: 80, ForwardHost: "127.0.0.1"},
+		{ListenPort: 80, Protocol: "tcp", ForwardPort: 8080, ForwardHost: "127.0.0.1"},
+	}, ServerResourceModelListenerValue{types.ListNull(types.ObjectType{AttrTypes: serverListenerAttrTypes})})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	plan := tfsdk.Plan{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	diags = plan.Set(ctx, &ServerResourceModel{
+		Name:           types.StringValue("web"),
+		FirewallId:     types.StringValue("fw"),
+		GroupIds:       types.ListNull(types.StringType),
+		GroupObjectIds: types.ListNull(types.StringType),
+		GroupNames:     types.ListNull(types.StringType),
+		Listeners:      listeners,
+	})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	resp := &fwresource.ValidateConfigResponse{}
+	r.ValidateConfig(ctx, fwresource.ValidateConfigRequest{Config: tfsdk.Config{Schema: plan.Schema, Raw: plan.Raw}}, resp)
+	if resp.Diagnostics.ErrorsCount() != 1 {
+		t.Fatalf("expected one duplicate listener error, got %v", resp.Diagnostics)
+	}
+	errPath := resp.Diagnostics.Errors()[0].(diag.DiagnosticWithPath).Path()
+	if !errPath.Equal(path.Root("listeners").AtListIndex(2).AtName("listen_port")) {
+		t.Fatalf("unexpected error path %s: %v", errPath, resp.Diagnostics)
+	}
+}
@@ -0,0 +1,199 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
+)
+
+const (
+	testAccFakeApiKey = "test-api-key"
+	// OAuth2 client and CI issued OIDC token exchanged for testAccFakeAccessToken
+	testAccFakeClientID     = "terraform"
+	testAccFakeClientSecret = "test-client-secret"
+	testAccFakeOIDCToken    = "test-oidc-token"
+	testAccFakeAccessToken  = "test-access-token"
+	testAccFakeTokenPath    = "/oauth/token"
+)
+
+// testAccFakeServer is an in-memory implementation of the Shieldoo CLI API
+// used by the acceptance tests. It shares the entity handling with the
+// offline backend and adds JWT validation on top of it.
+type testAccFakeServer struct {
+	*httptest.Server
+	backend *shieldoo.OfflineBackend
+	// client talks to the backend directly and is used by the test checks.
+	client *shieldoo.Client
+}
+
+func newTestAccFakeServer(t *testing.T) *testAccFakeServer {
+	t.Helper()
+	backend := shieldoo.NewMemoryBackend(
+		shieldoo.Group{Id: "group-admins", Name: "admins", ObjectId: "object-admins"},
+		shieldoo.Group{Id: "group-developers", Name: "developers", ObjectId: "object-developers"},
+	)
+	client, err := shieldoo.NewClient("https://fake.shieldoo.invalid", shieldoo.WithBackend(backend), shieldoo.WithCache(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &testAccFakeServer{backend: backend, client: client}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.handle))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// ProviderConfig returns the provider block pointing to the fake server.
+func (f *testAccFakeServer) ProviderConfig() string {
+	return fmt.Sprintf(`
+provider "shieldoo" {
+	endpoint = %q
+	apikey   = %q
+}
+`, f.URL, testAccFakeApiKey)
+}
+
+func (f *testAccFakeServer) checkToken(r *http.Request) error {
+	if bearer := r.Header.Get("Authorization"); bearer != "" {
+		if bearer != "Bearer "+testAccFakeAccessToken {
+			return errors.New("invalid bearer token")
+		}
+		return nil
+	}
+	token := r.Header.Get("AuthToken")
+	if token == "" {
+		return errors.New("missing AuthToken header")
+	}
+	claims := &shieldoo.TokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS512 {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		return []byte(testAccFakeApiKey), nil
+	})
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return err
+	}
+	if claims.ShieldooClaims["instance"] != u.Hostname() {
+		return fmt.Errorf("invalid instance claim %q", claims.ShieldooClaims["instance"])
+	}
+	return nil
+}
+
+// handleToken issues testAccFakeAccessToken for the OAuth2 client
+// credentials grant and for the workload identity token exchange.
+func (f *testAccFakeServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTestAccError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch {
+	case r.PostForm.Get("grant_type") == "client_credentials" &&
+		r.PostForm.Get("client_id") == testAccFakeClientID && r.PostForm.Get("client_secret") == testAccFakeClientSecret:
+	case r.PostForm.Get("grant_type") == "urn:ietf:params:oauth:grant-type:token-exchange" &&
+		r.PostForm.Get("subject_token") == testAccFakeOIDCToken:
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, `{"error":"invalid_client"}`)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": testAccFakeAccessToken, "token_type": "Bearer", "expires_in": 3600})
+}
+
+func (f *testAccFakeServer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == testAccFakeTokenPath || r.URL.Path == shieldoo.DefaultTokenExchangePath {
+		f.handleToken(w, r)
+		return
+	}
+	if err := f.checkToken(r); err != nil {
+		writeTestAccError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/cliapi/")
+	if path == r.URL.Path {
+		writeTestAccError(w, http.StatusNotFound, "unknown path")
+		return
+	}
+	entity, id, _ := strings.Cut(path, "/")
+	id, err := url.QueryUnescape(id)
+	if err != nil {
+		writeTestAccError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeTestAccError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ret, err := f.backend.Call(r.Context(), r.Method, entity, r.URL.Query().Get("name"), id, body)
+
+	var apiErr *shieldoo.APIError
+	if errors.As(err, &apiErr) {
+		writeTestAccError(w, apiErr.StatusCode, apiErr.Message)
+		return
+	}
+	if err != nil {
+		writeTestAccError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, ret)
+}
+
+func writeTestAccError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+func TestFakeServerRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	fake := newTestAccFakeServer(t)
+
+	unauthorized, err := shieldoo.NewClient(fake.URL, shieldoo.WithAPIKey("wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unauthorized.ListGroups(ctx); err == nil {
+		t.Fatal("expected token signed with a wrong key to be rejected")
+	}
+
+	client, err := shieldoo.NewClient(fake.URL, shieldoo.WithAPIKey(testAccFakeApiKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw, err := client.CreateFirewall(ctx, &shieldoo.Firewall{Name: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetFirewall(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != fw.Id {
+		t.Fatalf("expected firewall %s, got %s", fw.Id, got.Id)
+	}
+	if err := client.DeleteFirewall(ctx, fw.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetFirewall(ctx, "default"); !shieldoo.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
+)
+
+// optionalString maps an API value onto an optional attribute, the zero
+// value keeps an attribute the user omitted null.
+func optionalString(v string, prior types.String) types.String {
+	if v == "" && prior.IsNull() {
+		return types.StringNull()
+	}
+	return types.StringValue(v)
+}
+
+func optionalBool(v bool, prior types.Bool) types.Bool {
+	if !v && prior.IsNull() {
+		return types.BoolNull()
+	}
+	return types.BoolValue(v)
+}
+
+func optionalInt64(v int64, prior types.Int64) types.Int64 {
+	if v == 0 && prior.IsNull() {
+		return types.Int64Null()
+	}
+	return types.Int64Value(v)
+}
+
+// groupRefs are the group references of a server or a firewall rule split by
+// the attribute they are declared in.
+type groupRefs struct {
+	Ids       types.List
+	ObjectIds types.List
+	Names     types.List
+}
+
+// renderGroupRefs converts the groups returned by the API into group
+// references. Each group stays in the attribute it was declared in before,
+// in the declared order. Groups unknown to prior, e.g. added in the admin UI
+// or read during import, are referenced by ID.
+func renderGroupRefs(ctx context.Context, groups []shieldoo.Group, prior groupRefs) (groupRefs, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	matched := make([]bool, len(groups))
+	match := func(declared types.List, key func(shieldoo.Group) string) []string {
+		ret := []string{}
+		for _, v := range declared.Elements() {
+			s, ok := v.(types.String)
+			if !ok {
+				continue
+			}
+			for i, g := range groups {
+				if !matched[i] && key(g) != "" && key(g) == s.ValueString() {
+					matched[i] = true
+					ret = append(ret, s.ValueString())
+					break
+				}
+			}
+		}
+		return ret
+	}
+	ids := match(prior.Ids, func(g shieldoo.Group) string { return g.Id })
+	objectIds := match(prior.ObjectIds, func(g shieldoo.Group) string { return g.ObjectId })
+	names := match(prior.Names, func(g shieldoo.Group) string { return g.Name })
+	for i, g := range groups {
+		if !matched[i] {
+			ids = append(ids, g.Id)
+		}
+	}
+
+	list := func(values []string, prior types.List) types.List {
+		if len(values) == 0 && prior.IsNull() {
+			return types.ListNull(types.StringType)
+		}
+		ret, d := types.ListValueFrom(ctx, types.StringType, values)
+		diags.Append(d...)
+		return ret
+	}
+	return groupRefs{
+		Ids:       list(ids, prior.Ids),
+		ObjectIds: list(objectIds, prior.ObjectIds),
+		Names:     list(names, prior.Names),
+	}, diags
+}
+
+// parseImportID splits an import ID of the form name:<name> or id:<id>, a
+// value without prefix is an ID.
+func parseImportID(importID string) (byName bool, value string, err error) {
+	switch {
+	case strings.HasPrefix(importID, "name:"):
+		byName, value = true, strings.TrimPrefix(importID, "name:")
+	case strings.HasPrefix(importID, "id:"):
+		value = strings.TrimPrefix(importID, "id:")
+	default:
+		value = importID
+	}
+	if value == "" {
+		return false, "", fmt.Errorf("expected name:<name>, id:<id> or <id>, got %q", importID)
+	}
+	return byName, value, nil
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/shieldoo/terraform-provider-shieldoo/pkg/shieldoo"
+)
+
+func TestRenderGroupRefs(t *testing.T) {
+	ctx := context.Background()
+	list := func(values ...string) types.List {
+		l, _ := types.ListValueFrom(ctx, types.StringType, values)
+		return l
+	}
+	groups := []shieldoo.Group{
+		{Id: "g1", Name: "admins", ObjectId: "o1"},
+		{Id: "g2", Name: "developers", ObjectId: "o2"},
+		{Id: "g3", Name: "ops", ObjectId: "o3"},
+	}
+	prior := groupRefs{
+		Ids:       types.ListNull(types.StringType),
+		ObjectIds: list("o2"),
+		Names:     list("removed", "admins"),
+	}
+
+	got, diags := renderGroupRefs(ctx, groups, prior)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	if !got.ObjectIds.Equal(list("o2")) {
+		t.Fatalf("unexpected object ids %s", got.ObjectIds)
+	}
+	if !got.Names.Equal(list("admins")) {
+		t.Fatalf("unexpected names %s", got.Names)
+	}
+	// groups added outside of Terraform are referenced by ID
+	if !got.Ids.Equal(list("g3")) {
+		t.Fatalf("unexpected ids %s", got.Ids)
+	}
+
+	got, diags = renderGroupRefs(ctx, nil, groupRefs{Ids: types.ListNull(types.StringType), ObjectIds: types.ListNull(types.StringType), Names: list()})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	if !got.Ids.IsNull() || !got.ObjectIds.IsNull() || !got.Names.Equal(list()) {
+		t.Fatalf("expected omitted lists to stay null and empty lists empty, got %+v", got)
+	}
+}
+
+func TestServerListenersToModel(t *testing.T) {
+	prior, diags := ServerListenersToModel([]shieldoo.Listener{{ListenPort: 80, Protocol: "tcp", ForwardPort: 80, ForwardHost: "127.0.0.1"}}, ServerResourceModelListenerValue{types.ListNull(types.ObjectType{AttrTypes: serverListenerAttrTypes})})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	description := prior.Elements()[0].(types.Object).Attributes()["description"]
+	if !description.IsNull() {
+		t.Fatalf("expected empty description to stay null, got %s", description)
+	}
+
+	got, diags := ServerListenersToModel([]shieldoo.Listener{
+		{ListenPort: 8080, Protocol: "tcp", ForwardPort: 80, ForwardHost: "127.0.0.1", Description: "web"},
+		{ListenPort: 53, Protocol: "udp", ForwardPort: 53, ForwardHost: "10.0.0.2"},
+	}, prior)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	parsed := got.ParseServerListenersFromModel(context.Background())
+	if len(parsed) != 2 || parsed[0].ListenPort != 8080 || parsed[0].Description != "web" || parsed[1].Protocol != "udp" {
+		t.Fatalf("unexpected listeners %+v", parsed)
+	}
+}
+
+func TestParseImportID(t *testing.T) {
+	tests := map[string]struct {
+		byName bool
+		value  string
+	}{
+		"name:web-01": {byName: true, value: "web-01"},
+		"id:abc":      {value: "abc"},
+		"abc":         {value: "abc"},
+		"name:a:b":    {byName: true, value: "a:b"},
+	}
+	for id, tt := range tests {
+		byName, value, err := parseImportID(id)
+		if err != nil || byName != tt.byName || value != tt.value {
+			t.Fatalf("%s: unexpected %v %q %v", id, byName, value, err)
+		}
+	}
+	for _, id := range []string{"", "name:", "id:"} {
+		if _, _, err := parseImportID(id); err == nil {
+			t.Fatalf("expected %q to be rejected", id)
+		}
+	}
+}
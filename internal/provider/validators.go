@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+// hostnameRegexp matches RFC 1123 host names.
+var hostnameRegexp = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*\.?$`)
+
+// dottedNumbersRegexp matches values made of numbers only, which are meant
+// as IPv4 addresses rather than host names.
+var dottedNumbersRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*\.?$`)
+
+// hostnameOrIPValidator checks that a string is an IP address or a host name.
+type hostnameOrIPValidator struct{}
+
+var _ validator.String = hostnameOrIPValidator{}
+
+func hostnameOrIP() hostnameOrIPValidator {
+	return hostnameOrIPValidator{}
+}
+
+func (v hostnameOrIPValidator) Description(ctx context.Context) string {
+	return "value must be an IP address or a host name"
+}
+
+func (v hostnameOrIPValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v hostnameOrIPValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	value := req.ConfigValue.ValueString()
+	if net.ParseIP(value) != nil ||
+		(len(value) <= 253 && hostnameRegexp.MatchString(value) && !dottedNumbersRegexp.MatchString(value)) {
+		return
+	}
+	resp.Diagnostics.AddAttributeError(
+		req.Path,
+		"Invalid Attribute Value",
+		fmt.Sprintf("Attribute %s %s, got: %q", req.Path, v.Description(ctx), value),
+	)
+}
+
+// parseFirewallPort checks a firewall rule port: a single port, a range
+// start-end or any.
+func parseFirewallPort(port string) error {
+	if port == "any" {
+		return nil
+	}
+	parse := func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil || strconv.Itoa(n) != s {
+			return 0, fmt.Errorf("%q is not a port number", s)
+		}
+		if n < 1 || n > 65535 {
+			return 0, fmt.Errorf("port %d is not between 1 and 65535", n)
+		}
+		return n, nil
+	}
+	if start, end, ok := strings.Cut(port, "-"); ok {
+		from, err := parse(start)
+		if err != nil {
+			return err
+		}
+		to, err := parse(end)
+		if err != nil {
+			return err
+		}
+		if from > to {
+			return fmt.Errorf("range start %d is greater than its end %d", from, to)
+		}
+		return nil
+	}
+	_, err := parse(port)
+	return err
+}
+
+// firewallPortValidator checks a firewall rule port, see parseFirewallPort.
+type firewallPortValidator struct{}
+
+var _ validator.String = firewallPortValidator{}
+
+func firewallPort() firewallPortValidator {
+	return firewallPortValidator{}
+}
+
+func (v firewallPortValidator) Description(ctx context.Context) string {
+	return "value must be a port (1-65535), a port range start-end or any"
+}
+
+func (v firewallPortValidator) MarkdownDescription(ctx context.Context) string {
+	return "value must be a port (`1`-`65535`), a port range `start-end` or `any`"
+}
+
+func (v firewallPortValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if err := parseFirewallPort(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid Attribute Value",
+			fmt.Sprintf("Attribute %s %s, got: %q (%s)", req.Path, v.Description(ctx), req.ConfigValue.ValueString(), err),
+		)
+	}
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestHostnameOrIP(t *testing.T) {
+	ctx := context.Background()
+	for value, invalid := range map[string]bool{
+		"127.0.0.1":         false,
+		"::1":               false,
+		"localhost":         false,
+		"db-01.example.com": false,
+		"http://localhost":  true,
+		"bad host":          true,
+		"-leading.dash":     true,
+		"999.1.1.1":         true,
+		"1.2.3":             true,
+		"10":                true,
+		"1.2.3.4.":          true,
+		"1password.com":     false,
+		"":                  true,
+	} {
+		resp := &validator.StringResponse{}
+		hostnameOrIP().ValidateString(ctx, validator.StringRequest{Path: path.Root("forward_host"), ConfigValue: types.StringValue(value)}, resp)
+		if resp.Diagnostics.HasError() != invalid {
+			t.Fatalf("%q: expected invalid=%v, got %v", value, invalid, resp.Diagnostics)
+		}
+	}
+}
+
+func TestParseFirewallPort(t *testing.T) {
+	for port, invalid := range map[string]bool{
+		"any":         false,
+		"1":           false,
+		"65535":       false,
+		"1000-2000":   false,
+		"80-80":       false,
+		"0":           true,
+		"65536":       true,
+		"080":         true,
+		"2000-1000":   true,
+		"1-65536":     true,
+		"1-2-3":       true,
+		"-80":         true,
+		"http":        true,
+		"":            true,
+		"1000 - 2000": true,
+	} {
+		if err := parseFirewallPort(port); (err != nil) != invalid {
+			t.Fatalf("%q: expected invalid=%v, got %v", port, invalid, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package shieldoo
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheDependencies lists entities embedding other entities, writing the key
+// entity invalidates the cached responses of the listed ones too.
+var cacheDependencies = map[string][]string{
+	"firewalls": {"servers"},
+	"groups":    {"servers", "firewalls"},
+}
+
+type responseCacheEntry struct {
+	data    string
+	expires time.Time
+}
+
+// responseCache keeps GET responses for the lifetime of one client.
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]responseCacheEntry
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		entries: map[string]responseCacheEntry{},
+	}
+}
+
+func responseCacheKey(entity string, name string, id string) string {
+	return entity + "/" + id + "?" + name
+}
+
+func (c *responseCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.data, true
+}
+
+func (c *responseCache) Set(key string, data string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = responseCacheEntry{
+		data:    data,
+		expires: time.Now().Add(c.ttl),
+	}
+}
+
+// Invalidate drops all cached responses of the entity and its dependents.
+func (c *responseCache) Invalidate(entity string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entities := append([]string{entity}, cacheDependencies[entity]...)
+	for key := range c.entries {
+		for _, e := range entities {
+			if strings.HasPrefix(key, e+"/") {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
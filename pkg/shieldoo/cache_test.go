@@ -0,0 +1,51 @@
+package shieldoo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheInvalidatesOnWrite(t *testing.T) {
+	gets := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets++
+			_, _ = w.Write([]byte(`[{"id":"1","name":"fw"}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"1","name":"fw"}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := &Client{uri: srv.URL, apiKey: "test", cache: newResponseCache(time.Minute)}
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetFirewall(ctx, "fw"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gets != 1 {
+		t.Fatalf("expected 1 GET, got %d", gets)
+	}
+	if _, err := client.UpdateFirewall(ctx, &Firewall{Id: "1", Name: "fw"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetFirewall(ctx, "fw"); err != nil {
+		t.Fatal(err)
+	}
+	if gets != 2 {
+		t.Fatalf("expected cache to be invalidated by write, got %d GETs", gets)
+	}
+}
+
+func TestResponseCacheExpires(t *testing.T) {
+	cache := newResponseCache(time.Millisecond)
+	cache.Set("servers/?a", "[]")
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("servers/?a"); ok {
+		t.Fatal("expected expired entry to be dropped")
+	}
+}
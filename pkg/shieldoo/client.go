@@ -0,0 +1,560 @@
+package shieldoo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// DefaultTokenLifetime is the lifetime of the signed API access token.
+const DefaultTokenLifetime = 5 * time.Minute
+
+// TokenClaims are the claims of the JWT signed with the API key and sent in
+// the AuthToken header.
+type TokenClaims struct {
+	jwt.RegisteredClaims
+	ShieldooClaims map[string]string `json:"shieldoo"`
+}
+
+// Backend executes CLI API calls without going through HTTP.
+// The body is the JSON encoded request and the result is the JSON encoded
+// response, exactly as the HTTP API would return them.
+type Backend interface {
+	Call(ctx context.Context, method string, entity string, name string, id string, body []byte) (string, error)
+}
+
+// Client calls the Shieldoo CLI API. It is safe for concurrent use, create
+// it with NewClient.
+type Client struct {
+	// backend replaces the HTTP API when set (e.g. the offline backend)
+	backend Backend
+	uri     string
+	apiKey  string
+	// secondaryAPIKey is tried once the API rejects apiKey, see WithSecondaryAPIKey
+	secondaryAPIKey string
+	// instance overrides the instance claim derived from uri
+	instance string
+	// auth replaces the JWT signed with apiKey by a bearer token when set
+	auth         tokenSource
+	maxRetries   int
+	retryMaxWait time.Duration
+	// idempotentPost sends an Idempotency-Key with POST, see WithIdempotentPost
+	idempotentPost bool
+	httpClient     *http.Client
+	// limiter and requests throttle the HTTP API calls, nil means unlimited
+	limiter  *rateLimiter
+	requests semaphore
+	// cache keeps GET responses for the lifetime of the client, nil disables caching
+	cache *responseCache
+	// JWT settings, zero values mean defaults
+	tokenLifetime  time.Duration
+	tokenClockSkew time.Duration
+	tokenClaims    map[string]string
+	// signed JWT reused until shortly before it expires
+	tokenMu      sync.Mutex
+	token        string
+	tokenExpires time.Time
+	// useSecondaryKey is set for the rest of the run once apiKey was rejected
+	useSecondaryKey bool
+	// secondaryKeyReported is set once ReportSecondaryAPIKeySwitch returned true
+	secondaryKeyReported bool
+}
+
+// ListGroups returns all groups of the tenant.
+func (c *Client) ListGroups(ctx context.Context) ([]Group, error) {
+	data, err := c.callApi(ctx, "GET", "groups", "", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var groups []Group
+	err = json.Unmarshal([]byte(data), &groups)
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GetServer returns the server with exactly the given name. ErrNotFound or
+// ErrAmbiguous is returned when there is no such server or more of them.
+func (c *Client) GetServer(ctx context.Context, name string) (*Server, error) {
+	data, err := c.callApi(ctx, "GET", "servers", name, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var servers []Server
+	if err := decodeList(data, &servers); err != nil {
+		return nil, err
+	}
+	// the name filter may return more entities, pick the exact match
+	var matches []Server
+	for _, s := range servers {
+		if s.Name == name {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("server %q: %w", name, ErrNotFound)
+	case 1:
+		return &matches[0], nil
+	}
+	return nil, fmt.Errorf("server %q: %w: %d matches", name, ErrAmbiguous, len(matches))
+}
+
+// GetServerByID returns the server with the given ID.
+func (c *Client) GetServerByID(ctx context.Context, id string) (*Server, error) {
+	data, err := c.callApi(ctx, "GET", "servers", "", id, nil)
+	if err != nil {
+		return nil, err
+	}
+	var server Server
+	if err := decodeEntity(data, &server); err != nil {
+		return nil, fmt.Errorf("server %q: %w", id, err)
+	}
+	return &server, nil
+}
+
+// DeleteServer deletes the server with the given ID.
+func (c *Client) DeleteServer(ctx context.Context, id string) error {
+	_, err := c.callApi(ctx, "DELETE", "servers", "", id, nil)
+	return err
+}
+
+// CreateServer creates a server and returns it as stored by the API.
+func (c *Client) CreateServer(ctx context.Context, server *Server) (*Server, error) {
+	data, err := c.callApi(ctx, "POST", "servers", "", "", server)
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		// created without content, read the new server back
+		return c.GetServer(ctx, server.Name)
+	}
+	var newServer Server
+	err = json.Unmarshal([]byte(data), &newServer)
+	if err != nil {
+		return nil, err
+	}
+	return &newServer, nil
+}
+
+// UpdateServer updates the server identified by server.Id.
+func (c *Client) UpdateServer(ctx context.Context, server *Server) (*Server, error) {
+	data, err := c.callApi(ctx, "PUT", "servers", "", server.Id, server)
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		// updated without content, read the server back
+		return c.GetServerByID(ctx, server.Id)
+	}
+	var newServer Server
+	err = json.Unmarshal([]byte(data), &newServer)
+	if err != nil {
+		return nil, err
+	}
+	return &newServer, nil
+}
+
+// GetFirewall returns the firewall with exactly the given name. ErrNotFound
+// or ErrAmbiguous is returned when there is no such firewall or more of them.
+func (c *Client) GetFirewall(ctx context.Context, name string) (*Firewall, error) {
+	data, err := c.callApi(ctx, "GET", "firewalls", name, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var firewalls []Firewall
+	if err := decodeList(data, &firewalls); err != nil {
+		return nil, err
+	}
+	// the name filter may return more entities, pick the exact match
+	var matches []Firewall
+	for _, f := range firewalls {
+		if f.Name == name {
+			matches = append(matches, f)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("firewall %q: %w", name, ErrNotFound)
+	case 1:
+		return &matches[0], nil
+	}
+	return nil, fmt.Errorf("firewall %q: %w: %d matches", name, ErrAmbiguous, len(matches))
+}
+
+// GetFirewallByID returns the firewall with the given ID.
+func (c *Client) GetFirewallByID(ctx context.Context, id string) (*Firewall, error) {
+	data, err := c.callApi(ctx, "GET", "firewalls", "", id, nil)
+	if err != nil {
+		return nil, err
+	}
+	var firewall Firewall
+	if err := decodeEntity(data, &firewall); err != nil {
+		return nil, fmt.Errorf("firewall %q: %w", id, err)
+	}
+	return &firewall, nil
+}
+
+// DeleteFirewall deletes the firewall with the given ID.
+func (c *Client) DeleteFirewall(ctx context.Context, id string) error {
+	_, err := c.callApi(ctx, "DELETE", "firewalls", "", id, nil)
+	return err
+}
+
+// CreateFirewall creates a firewall and returns it as stored by the API.
+func (c *Client) CreateFirewall(ctx context.Context, firewall *Firewall) (*Firewall, error) {
+	data, err := c.callApi(ctx, "POST", "firewalls", "", "", firewall)
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		// created without content, read the new firewall back
+		return c.GetFirewall(ctx, firewall.Name)
+	}
+	var ret Firewall
+	err = json.Unmarshal([]byte(data), &ret)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// UpdateFirewall updates the firewall identified by firewall.Id.
+func (c *Client) UpdateFirewall(ctx context.Context, firewall *Firewall) (*Firewall, error) {
+	data, err := c.callApi(ctx, "PUT", "firewalls", "", firewall.Id, firewall)
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		// updated without content, read the firewall back
+		return c.GetFirewallByID(ctx, firewall.Id)
+	}
+	var ret Firewall
+	err = json.Unmarshal([]byte(data), &ret)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// decodeEntity decodes the response of a lookup by ID, which must be a JSON
+// object. An empty 2xx response is reported instead of failing in the decoder.
+func decodeEntity(data string, v interface{}) error {
+	if strings.TrimSpace(data) == "" {
+		return errors.New("the API returned an empty response")
+	}
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return fmt.Errorf("unable to decode API response: %w", err)
+	}
+	return nil
+}
+
+// decodeList decodes a lookup response, which is normally a JSON array but
+// may also be a single object or empty.
+func decodeList(data string, v interface{}) error {
+	data = strings.TrimSpace(data)
+	if data == "" || data == "null" {
+		data = "[]"
+	}
+	if !strings.HasPrefix(data, "[") {
+		data = "[" + data + "]"
+	}
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return fmt.Errorf("unable to decode API response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) generateJWTAccessToken() (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	lifetime := c.tokenLifetime
+	if lifetime <= 0 {
+		lifetime = DefaultTokenLifetime
+	}
+	now := time.Now()
+	// refresh the token before it expires, so it is still valid on arrival
+	if c.token != "" && now.Add(lifetime/5).Before(c.tokenExpires) {
+		return c.token, nil
+	}
+
+	instance := c.Instance()
+	shieldooClaims := map[string]string{}
+	for k, v := range c.tokenClaims {
+		shieldooClaims[k] = v
+	}
+	shieldooClaims["instance"] = instance
+	// prepare claims for token
+	expires := now.Add(lifetime)
+	claims := TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			// set token lifetime in timestamp
+			ExpiresAt: jwt.NewNumericDate(expires),
+			// backdate the token, so servers with a clock behind ours accept it
+			IssuedAt:  jwt.NewNumericDate(now.Add(-c.tokenClockSkew)),
+			NotBefore: jwt.NewNumericDate(now.Add(-c.tokenClockSkew)),
+		},
+		ShieldooClaims: shieldooClaims,
+	}
+
+	// generate a string using claims and HS512 algorithm
+	tokenString := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+
+	// sign the generated key using secretKey
+	key := c.apiKey
+	if c.useSecondaryKey {
+		key = c.secondaryAPIKey
+	}
+	token, err := tokenString.SignedString([]byte(key))
+	if err != nil {
+		return "", err
+	}
+
+	c.token = token
+	c.tokenExpires = expires
+	return token, nil
+}
+
+// UsingSecondaryAPIKey reports whether the API rejected the primary API key
+// and the client switched to the secondary one.
+func (c *Client) UsingSecondaryAPIKey() bool {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	return c.useSecondaryKey
+}
+
+// ReportSecondaryAPIKeySwitch returns true on the first call after the client
+// switched to the secondary API key and false otherwise, so callers can
+// report the switch once.
+func (c *Client) ReportSecondaryAPIKeySwitch() bool {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if !c.useSecondaryKey || c.secondaryKeyReported {
+		return false
+	}
+	c.secondaryKeyReported = true
+	return true
+}
+
+// renewCredentials is called after the API rejected req with 401. It drops
+// the rejected bearer token, or switches to the secondary API key, and
+// reports whether the request should be sent again with the new credentials.
+func (c *Client) renewCredentials(ctx context.Context, req *http.Request) bool {
+	if c.auth != nil {
+		tflog.SubsystemDebug(ctx, logSubsystem, "Shieldoo API rejected the bearer token, requesting a new one")
+		c.auth.Invalidate(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+		return true
+	}
+	return c.fallbackToSecondaryKey(ctx, req.Header.Get("AuthToken"))
+}
+
+// fallbackToSecondaryKey switches to the secondary API key after a request
+// signed with token was rejected. It reports whether the request should be
+// sent again, i.e. a different key is now in use.
+func (c *Client) fallbackToSecondaryKey(ctx context.Context, token string) bool {
+	if c.secondaryAPIKey == "" {
+		return false
+	}
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if c.useSecondaryKey {
+		// another request switched keys meanwhile, retry unless this one already used the secondary key
+		return token != c.token
+	}
+	tflog.SubsystemWarn(ctx, logSubsystem, "Shieldoo API rejected the primary API key, using the secondary key")
+	c.useSecondaryKey = true
+	c.token = ""
+	return true
+}
+
+// Instance returns the Shieldoo instance the access tokens are issued for,
+// the endpoint hostname unless it was set with WithInstance.
+func (c *Client) Instance() string {
+	if c.instance != "" {
+		return c.instance
+	}
+	return c.shieldooExtractDomainFromUri()
+}
+
+func (c *Client) shieldooExtractDomainFromUri() string {
+	// extract domain from uri
+	parsedURL, err := url.Parse(c.uri)
+	ret := ""
+	if err == nil {
+		ret = parsedURL.Hostname()
+	}
+	return ret
+}
+
+func (c *Client) callApi(ctx context.Context, method string, entity string, name string, id string, data interface{}) (string, error) {
+	ctx = c.logContext(ctx)
+	ctx = tflog.SubsystemSetField(ctx, logSubsystem, "shieldoo_method", method)
+	ctx = tflog.SubsystemSetField(ctx, logSubsystem, "shieldoo_entity", entity)
+	if id != "" {
+		ctx = tflog.SubsystemSetField(ctx, logSubsystem, "shieldoo_id", id)
+	}
+	if name != "" {
+		ctx = tflog.SubsystemSetField(ctx, logSubsystem, "shieldoo_name", name)
+	}
+	var jsonData []byte
+	// convert data to json if it is not nil
+	if data != nil {
+		var err error
+		jsonData, err = json.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+	}
+	if c.cache != nil {
+		if method == http.MethodGet {
+			key := responseCacheKey(entity, name, id)
+			if ret, ok := c.cache.Get(key); ok {
+				tflog.SubsystemTrace(ctx, logSubsystem, "Shieldoo API response served from cache")
+				return ret, nil
+			}
+			ret, err := c.send(ctx, method, entity, name, id, jsonData)
+			if err == nil {
+				c.cache.Set(key, ret)
+			}
+			return ret, err
+		}
+		// invalidate even when the write fails, it may have been applied partially
+		defer c.cache.Invalidate(entity)
+	}
+	return c.send(ctx, method, entity, name, id, jsonData)
+}
+
+func (c *Client) send(ctx context.Context, method string, entity string, name string, id string, jsonData []byte) (string, error) {
+	if c.backend != nil {
+		return c.backend.Call(ctx, method, entity, name, id, jsonData)
+	}
+	myurl := c.uri + "/cliapi/" + entity
+	if id != "" {
+		myurl += "/" + url.QueryEscape(id)
+	}
+	if name != "" {
+		// url encode name
+		myurl += "?name=" + url.QueryEscape(name)
+	}
+	// the same idempotency key is sent with every attempt, so POST can be retried
+	idempotencyKey := ""
+	if method == http.MethodPost && c.idempotentPost {
+		idempotencyKey = newIdempotencyKey()
+	}
+	renewedCredentials := false
+	for attempt := 0; ; attempt++ {
+		resp, body, err := c.doRequest(ctx, method, myurl, jsonData, idempotencyKey)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return successBody(resp, body)
+		}
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && !renewedCredentials &&
+			c.renewCredentials(ctx, resp.Request) {
+			// new credentials are not a retry of a transient failure, they do not count as an attempt
+			renewedCredentials = true
+			attempt--
+			continue
+		}
+		if attempt >= c.maxRetries || !isRetryableRequest(ctx, method, idempotencyKey, resp, err) {
+			if err != nil {
+				return "", err
+			}
+			return "", newAPIError(resp, body)
+		}
+		wait := c.retryWait(attempt, resp)
+		tflog.SubsystemWarn(ctx, logSubsystem, "retrying Shieldoo API call", map[string]interface{}{"attempt": attempt + 1, "wait": wait.String()})
+		if err := sleepContext(ctx, wait); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (c *Client) doRequest(ctx context.Context, method string, myurl string, jsonData []byte, idempotencyKey string) (*http.Response, []byte, error) {
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, method, myurl, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var token string
+	if c.auth != nil {
+		token, err = c.auth.Token(ctx, httpClient)
+		if err != nil {
+			return nil, nil, &AuthError{Err: err}
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		// create Jwt token
+		token, err = c.generateJWTAccessToken()
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("AuthToken", token)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.requests != nil {
+		if err := c.requests.Acquire(ctx); err != nil {
+			return nil, nil, err
+		}
+		defer c.requests.Release()
+	}
+	ctx = maskLogSecret(ctx, token)
+	tflog.SubsystemDebug(ctx, logSubsystem, "sending Shieldoo API request", map[string]interface{}{
+		"http_method": method,
+		"http_url":    myurl,
+	})
+	tflog.SubsystemTrace(ctx, logSubsystem, "Shieldoo API request body", map[string]interface{}{
+		"auth_token":   token,
+		"request_body": string(jsonData),
+	})
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		tflog.SubsystemDebug(ctx, logSubsystem, "Shieldoo API request failed", map[string]interface{}{
+			"error":      err.Error(),
+			"latency_ms": time.Since(start).Milliseconds(),
+		})
+		// prefer the context error so cancellation is reported clearly
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	tflog.SubsystemDebug(ctx, logSubsystem, "received Shieldoo API response", map[string]interface{}{
+		"http_method": method,
+		"http_url":    myurl,
+		"http_status": resp.StatusCode,
+		"latency_ms":  time.Since(start).Milliseconds(),
+	})
+	tflog.SubsystemTrace(ctx, logSubsystem, "Shieldoo API response body", map[string]interface{}{
+		"response_body": string(body),
+	})
+	return resp, body, nil
+}
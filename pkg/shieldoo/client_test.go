@@ -0,0 +1,183 @@
+package shieldoo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestGenerateJWTAccessTokenIsCached(t *testing.T) {
+	client := &Client{
+		uri:            "https://example.shieldoo.net",
+		apiKey:         "test",
+		tokenLifetime:  time.Minute,
+		tokenClockSkew: 30 * time.Second,
+		tokenClaims:    map[string]string{"team": "ops"},
+	}
+	first, err := client.generateJWTAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.generateJWTAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("expected cached token to be reused")
+	}
+
+	claims := &TokenClaims{}
+	if _, err := jwt.ParseWithClaims(first, claims, func(*jwt.Token) (interface{}, error) { return []byte("test"), nil }); err != nil {
+		t.Fatal(err)
+	}
+	if claims.ShieldooClaims["instance"] != "example.shieldoo.net" || claims.ShieldooClaims["team"] != "ops" {
+		t.Fatalf("unexpected shieldoo claims: %v", claims.ShieldooClaims)
+	}
+	if claims.IssuedAt == nil || claims.NotBefore == nil || time.Until(claims.NotBefore.Time) > -29*time.Second {
+		t.Fatalf("expected backdated iat/nbf, got %v %v", claims.IssuedAt, claims.NotBefore)
+	}
+
+	// a token close to its expiry is replaced
+	client.tokenExpires = time.Now().Add(time.Second)
+	if _, err := client.generateJWTAccessToken(); err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(client.tokenExpires) < 50*time.Second {
+		t.Fatal("expected token close to expiry to be refreshed")
+	}
+}
+
+func TestGetServerLookup(t *testing.T) {
+	responses := map[string]string{
+		"none":      `[]`,
+		"web":       `[{"id":"1","name":"web-01"},{"id":"2","name":"web"}]`,
+		"dup":       `[{"id":"1","name":"dup"},{"id":"2","name":"dup"}]`,
+		"singleobj": `{"id":"3","name":"singleobj"}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(responses[r.URL.Query().Get("name")]))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := &Client{uri: srv.URL, apiKey: "test"}
+
+	if _, err := client.GetServer(ctx, "none"); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if s, err := client.GetServer(ctx, "web"); err != nil || s.Id != "2" {
+		t.Fatalf("expected exact match, got %+v %v", s, err)
+	}
+	_, err := client.GetServer(ctx, "dup")
+	if !errors.Is(err, ErrAmbiguous) || !strings.Contains(err.Error(), "2 matches") {
+		t.Fatalf("expected ambiguous error, got %v", err)
+	}
+	if s, err := client.GetServer(ctx, "singleobj"); err != nil || s.Id != "3" {
+		t.Fatalf("expected single object to be accepted, got %+v %v", s, err)
+	}
+}
+
+func TestGetByIDEmptyResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := &Client{uri: srv.URL, apiKey: "test"}
+	if _, err := client.GetServerByID(ctx, "s1"); err == nil || !strings.Contains(err.Error(), `server "s1": the API returned an empty response`) {
+		t.Fatalf("expected empty response error, got %v", err)
+	}
+	if _, err := client.GetFirewallByID(ctx, "f1"); err == nil || !strings.Contains(err.Error(), `firewall "f1": the API returned an empty response`) {
+		t.Fatalf("expected empty response error, got %v", err)
+	}
+}
+
+func TestInstanceOverrideAndPathPrefix(t *testing.T) {
+	var gotPath, gotInstance string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		claims := &TokenClaims{}
+		if _, err := jwt.ParseWithClaims(r.Header.Get("AuthToken"), claims, func(*jwt.Token) (interface{}, error) { return []byte("test"), nil }); err != nil {
+			t.Error(err)
+		}
+		gotInstance = claims.ShieldooClaims["instance"]
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	for _, endpoint := range []string{srv.URL + "/corp/shieldoo", srv.URL + "/corp/shieldoo/", srv.URL + "/corp/shieldoo/cliapi"} {
+		client, err := NewClient(endpoint, WithAPIKey("test"), WithInstance("mytenant.shieldoo.net"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.ListGroups(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if gotPath != "/corp/shieldoo/cliapi/groups" {
+			t.Fatalf("unexpected API path %s for endpoint %s", gotPath, endpoint)
+		}
+		if gotInstance != "mytenant.shieldoo.net" {
+			t.Fatalf("unexpected instance claim %q", gotInstance)
+		}
+	}
+
+	_, err := NewClient(srv.URL, WithAPIKey("test"), WithTokenClaims(map[string]string{"instance": "other"}))
+	if err == nil || !strings.Contains(err.Error(), "WithInstance") {
+		t.Fatalf("expected the instance claim to be rejected, got %v", err)
+	}
+}
+
+func TestSecondaryAPIKeyFallback(t *testing.T) {
+	var primaryRequests, secondaryRequests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := jwt.Parse(r.Header.Get("AuthToken"), func(*jwt.Token) (interface{}, error) { return []byte("new-key"), nil })
+		if err != nil {
+			primaryRequests++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		secondaryRequests++
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(srv.URL, WithAPIKey("old-key"), WithSecondaryAPIKey("new-key"), WithRetries(0, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.ReportSecondaryAPIKeySwitch() {
+		t.Fatal("expected no switch to report before the first request")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.ListGroups(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !client.UsingSecondaryAPIKey() {
+		t.Fatal("expected the client to switch to the secondary key")
+	}
+	if !client.ReportSecondaryAPIKeySwitch() || client.ReportSecondaryAPIKeySwitch() {
+		t.Fatal("expected the switch to be reported exactly once")
+	}
+	if primaryRequests != 1 || secondaryRequests != 3 {
+		t.Fatalf("expected one rejected request and the secondary key kept, got %d/%d", primaryRequests, secondaryRequests)
+	}
+
+	// without a working key the 401 is reported after a single fallback
+	bad, err := NewClient(srv.URL, WithAPIKey("old-key"), WithSecondaryAPIKey("older-key"), WithRetries(0, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apiErr *APIError
+	if _, err := bad.ListGroups(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %v", err)
+	}
+}
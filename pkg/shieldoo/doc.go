@@ -0,0 +1,18 @@
+// Package shieldoo is a Go client for the Shieldoo CLI API.
+//
+// Create a client with NewClient and the options it needs:
+//
+//	client, err := shieldoo.NewClient("https://mytenant.shieldoo.net",
+//		shieldoo.WithAPIKey(os.Getenv("SHIELDOO_API_KEY")),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	server, err := client.GetServer(ctx, "web-01")
+//
+// All methods take a context, failed API calls return an *APIError and
+// missing entities can be detected with IsNotFound.
+//
+// Endpoints starting with file:// use a local offline backend instead of
+// the HTTP API, see NewFileBackend.
+package shieldoo
@@ -0,0 +1,140 @@
+package shieldoo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is reported when the requested entity does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrAmbiguous is reported when a name lookup matches more than one entity.
+var ErrAmbiguous = errors.New("ambiguous")
+
+// AuthError is reported when no access token could be obtained for a
+// request, e.g. because the identity provider rejected the credentials.
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return "authentication failed: " + e.Err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// APIError describes a non-successful response of the Shieldoo API.
+type APIError struct {
+	// StatusCode is the HTTP status code returned by the API.
+	StatusCode int
+	// Status is the HTTP status line, e.g. "404 Not Found".
+	Status string
+	// Message is the error message decoded from the response body, if any.
+	Message string
+	// Body is the raw response body.
+	Body string
+	// RequestID is the request identifier reported by the server, if any.
+	RequestID string
+	// ServerTime is the time of the Date response header, zero if missing.
+	ServerTime time.Time
+}
+
+func (e *APIError) Error() string {
+	msg := e.Status
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request id: %s)", e.RequestID)
+	}
+	return msg
+}
+
+// Is makes errors.Is(err, ErrNotFound) true for 404 responses.
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// IsNotFound reports whether err means that the entity does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+	}
+	if len(body) > 0 && isHTMLResponse(resp, body) {
+		// error pages of proxies and load balancers are not worth printing
+		apiErr.Message = "unexpected HTML response instead of JSON, check the endpoint and any proxy in front of it"
+	} else {
+		apiErr.Message = decodeErrorMessage(body)
+	}
+	for _, h := range []string{"X-Request-Id", "X-Correlation-Id", "Request-Id"} {
+		if v := resp.Header.Get(h); v != "" {
+			apiErr.RequestID = v
+			break
+		}
+	}
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		apiErr.ServerTime = date
+	}
+	return apiErr
+}
+
+// decodeErrorMessage extracts a readable message from a JSON error body,
+// falling back to the plain body text.
+func decodeErrorMessage(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		return ""
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return text
+	}
+	for _, key := range []string{"message", "error", "detail", "title"} {
+		if v, ok := decoded[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return text
+}
+
+// successBody validates the body of a 2xx response, an empty string is
+// returned for responses without content.
+func successBody(resp *http.Response, body []byte) (string, error) {
+	text := strings.TrimSpace(string(body))
+	if resp.StatusCode == http.StatusNoContent || text == "" {
+		return "", nil
+	}
+	if isHTMLResponse(resp, body) {
+		return "", fmt.Errorf("unexpected HTML in %s response, expected JSON (check the endpoint and any proxy in front of it): %s", resp.Status, truncate(text, 200))
+	}
+	return text, nil
+}
+
+// isHTMLResponse detects error pages returned by proxies, load balancers or
+// a wrong endpoint instead of the JSON API response.
+func isHTMLResponse(resp *http.Response, body []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && strings.Contains(mediaType, "html") {
+		return true
+	}
+	return strings.HasPrefix(strings.TrimSpace(string(body)), "<")
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
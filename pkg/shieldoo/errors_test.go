@@ -0,0 +1,69 @@
+package shieldoo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCallApiReturnsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message":"server does not exist"}`))
+	}))
+	defer srv.Close()
+
+	client := &Client{uri: srv.URL, apiKey: "test"}
+	_, err := client.UpdateServer(context.Background(), &Server{Id: "missing"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "server does not exist" || apiErr.RequestID != "req-1" {
+		t.Fatalf("unexpected error content: %+v", apiErr)
+	}
+	if !IsNotFound(err) {
+		t.Fatal("expected IsNotFound to be true")
+	}
+}
+
+func TestCallApiStatusClasses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"1","name":"fw"}`))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Query().Get("name") == "proxy":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body>Login</body></html>`))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`<html><body>Bad gateway</body></html>`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := &Client{uri: srv.URL, apiKey: "test"}
+	if fw, err := client.CreateFirewall(ctx, &Firewall{Name: "fw"}); err != nil || fw.Id != "1" {
+		t.Fatalf("expected 201 to be accepted, got %+v %v", fw, err)
+	}
+	if err := client.DeleteFirewall(ctx, "1"); err != nil {
+		t.Fatalf("expected 204 to be accepted, got %v", err)
+	}
+	if _, err := client.GetFirewall(ctx, "proxy"); err == nil || !strings.Contains(err.Error(), "unexpected HTML") {
+		t.Fatalf("expected HTML error, got %v", err)
+	}
+	_, err := client.GetFirewall(ctx, "other")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || strings.Contains(apiErr.Message, "<html>") {
+		t.Fatalf("expected readable API error, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package shieldoo
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// logSubsystem is the tflog subsystem of the API client, its level can be set
+// with TF_LOG_PROVIDER_SHIELDOO.
+const logSubsystem = "shieldoo"
+
+// secretBodyRegexps match secrets inside logged request and response bodies.
+var secretBodyRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`"configuration"\s*:\s*"[^"]*"`),
+	regexp.MustCompile(`"(apikey|apiKey|client_secret|access_token)"\s*:\s*"[^"]*"`),
+}
+
+// logContext prepares the subsystem logger for one API call, with all
+// credentials masked.
+func (c *Client) logContext(ctx context.Context) context.Context {
+	ctx = tflog.NewSubsystem(ctx, logSubsystem, tflog.WithLevelFromEnv("TF_LOG_PROVIDER", logSubsystem), tflog.WithRootFields())
+	ctx = tflog.SubsystemMaskFieldValuesWithFieldKeys(ctx, logSubsystem, "auth_token", "apikey", "configuration")
+	ctx = tflog.SubsystemMaskAllFieldValuesRegexes(ctx, logSubsystem, secretBodyRegexps...)
+	return maskLogSecret(ctx, c.apiKey)
+}
+
+// maskLogSecret masks the secret everywhere in the subsystem log output.
+func maskLogSecret(ctx context.Context, secret string) context.Context {
+	if secret == "" {
+		return ctx
+	}
+	ctx = tflog.SubsystemMaskAllFieldValuesStrings(ctx, logSubsystem, secret)
+	return tflog.SubsystemMaskMessageStrings(ctx, logSubsystem, secret)
+}
@@ -0,0 +1,40 @@
+package shieldoo
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+)
+
+func TestCallApiLogsAreRedacted(t *testing.T) {
+	t.Setenv("TF_LOG_PROVIDER_SHIELDOO", "trace")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id":"1","name":"web","configuration":"top-secret-config"}]`))
+	}))
+	defer srv.Close()
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+	client := &Client{uri: srv.URL, apiKey: "very-secret-api-key"}
+	if _, err := client.GetServer(ctx, "web"); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := output.String()
+	if !strings.Contains(logs, "received Shieldoo API response") || !strings.Contains(logs, `"http_status":200`) {
+		t.Fatalf("expected response to be logged, got: %s", logs)
+	}
+	for _, secret := range []string{"very-secret-api-key", "top-secret-config"} {
+		if strings.Contains(logs, secret) {
+			t.Fatalf("secret %q leaked into logs: %s", secret, logs)
+		}
+	}
+	if strings.Contains(logs, `"auth_token":"ey`) {
+		t.Fatalf("auth token leaked into logs: %s", logs)
+	}
+}
@@ -0,0 +1,159 @@
+package shieldoo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// defaultBearerTokenLifetime is assumed when the token response has no
+// expires_in.
+const defaultBearerTokenLifetime = 5 * time.Minute
+
+// bearerTokenRefreshMargin is how long before its expiry a bearer token is
+// replaced, so it is still valid when it arrives.
+const bearerTokenRefreshMargin = 30 * time.Second
+
+// ClientCredentialsConfig configures the OAuth2 client credentials grant
+// used to obtain bearer tokens from an identity provider.
+type ClientCredentialsConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// tokenSource provides the bearer token sent in the Authorization header
+// instead of the JWT signed with the API key.
+type tokenSource interface {
+	Token(ctx context.Context, httpClient *http.Client) (string, error)
+	// Invalidate drops token, which the API rejected, from the cache
+	Invalidate(token string)
+}
+
+// bearerToken is a cached access token of a token source.
+type bearerToken struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// get returns the cached token or fetches a new one when it is missing or
+// about to expire.
+func (b *bearerToken) get(fetch func() (string, time.Duration, error)) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.token != "" && time.Now().Add(bearerTokenRefreshMargin).Before(b.expires) {
+		return b.token, nil
+	}
+	token, lifetime, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	if lifetime <= 0 {
+		lifetime = defaultBearerTokenLifetime
+	}
+	b.token = token
+	b.expires = time.Now().Add(lifetime)
+	return token, nil
+}
+
+// invalidate drops the cached token if it is token, a token fetched
+// meanwhile by another request is kept.
+func (b *bearerToken) invalidate(token string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.token == token {
+		b.token = ""
+		b.expires = time.Time{}
+	}
+}
+
+type clientCredentialsSource struct {
+	cfg    ClientCredentialsConfig
+	cached bearerToken
+}
+
+func (s *clientCredentialsSource) Token(ctx context.Context, httpClient *http.Client) (string, error) {
+	return s.cached.get(func() (string, time.Duration, error) {
+		form := url.Values{}
+		form.Set("grant_type", "client_credentials")
+		// client_secret_post is accepted by all common identity providers
+		form.Set("client_id", s.cfg.ClientID)
+		form.Set("client_secret", s.cfg.ClientSecret)
+		if len(s.cfg.Scopes) > 0 {
+			form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+		}
+		tflog.SubsystemDebug(ctx, logSubsystem, "requesting OAuth2 access token", map[string]interface{}{
+			"token_url": s.cfg.TokenURL,
+			"client_id": s.cfg.ClientID,
+		})
+		return requestToken(ctx, httpClient, s.cfg.TokenURL, form)
+	})
+}
+
+func (s *clientCredentialsSource) Invalidate(token string) {
+	s.cached.invalidate(token)
+}
+
+// tokenResponse is the RFC 6749 access token response.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// requestToken posts the form to tokenURL and decodes the access token
+// response.
+func requestToken(ctx context.Context, httpClient *http.Client, tokenURL string, form url.Values) (string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("token request failed: %w", err)
+	}
+
+	var ret tokenResponse
+	// the error response is decoded too, it carries the reason of the failure
+	decodeErr := json.Unmarshal(body, &ret)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if ret.Error != "" {
+			msg := ret.Error
+			if ret.ErrorDescription != "" {
+				msg += ": " + ret.ErrorDescription
+			}
+			return "", 0, fmt.Errorf("token request failed with %s: %s", resp.Status, msg)
+		}
+		return "", 0, fmt.Errorf("token request failed with %s", resp.Status)
+	}
+	if decodeErr != nil {
+		return "", 0, fmt.Errorf("unable to decode token response: %w", decodeErr)
+	}
+	if ret.AccessToken == "" {
+		return "", 0, errors.New("token response contains no access_token")
+	}
+	if ret.TokenType != "" && !strings.EqualFold(ret.TokenType, "bearer") {
+		return "", 0, fmt.Errorf("unsupported token type %q", ret.TokenType)
+	}
+	return ret.AccessToken, time.Duration(ret.ExpiresIn) * time.Second, nil
+}
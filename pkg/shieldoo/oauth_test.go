@@ -0,0 +1,133 @@
+package shieldoo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClientCredentialsBearerToken(t *testing.T) {
+	var tokenRequests int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokenRequests, 1)
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("client_id") != "terraform" ||
+			r.PostForm.Get("client_secret") != "secret" || r.PostForm.Get("scope") != "shieldoo.read shieldoo.write" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"access-1","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer idp.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-1" || r.Header.Get("AuthToken") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer api.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(api.URL, WithClientCredentials(ClientCredentialsConfig{
+		TokenURL:     idp.URL,
+		ClientID:     "terraform",
+		ClientSecret: "secret",
+		Scopes:       []string{"shieldoo.read", "shieldoo.write"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.ListGroups(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&tokenRequests); n != 1 {
+		t.Fatalf("expected the access token to be cached, got %d token requests", n)
+	}
+
+	bad, err := NewClient(api.URL, WithClientCredentials(ClientCredentialsConfig{
+		TokenURL:     idp.URL,
+		ClientID:     "terraform",
+		ClientSecret: "wrong",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bad.ListGroups(ctx); err == nil || !strings.Contains(err.Error(), "invalid_client: bad credentials") {
+		t.Fatalf("expected token error, got %v", err)
+	}
+}
+
+func TestClientCredentialsRejectedTokenIsRenewed(t *testing.T) {
+	var tokenRequests int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&tokenRequests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	defer idp.Close()
+
+	var apiRequests int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&apiRequests, 1)
+		// access-1 was revoked before it expired
+		if r.Header.Get("Authorization") != "Bearer access-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer api.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(api.URL, WithClientCredentials(ClientCredentialsConfig{TokenURL: idp.URL, ClientID: "terraform", ClientSecret: "secret"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListGroups(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&tokenRequests); n != 2 {
+		t.Fatalf("expected one renewal of the rejected token, got %d token requests", n)
+	}
+	if n := atomic.LoadInt32(&apiRequests); n != 3 {
+		t.Fatalf("expected the rejected request to be sent once more, got %d API requests", n)
+	}
+
+	// a token rejected again is reported, not renewed in a loop
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer rejecting.Close()
+	client, err = NewClient(rejecting.URL, WithClientCredentials(ClientCredentialsConfig{TokenURL: idp.URL, ClientID: "terraform", ClientSecret: "secret"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apiErr *APIError
+	if _, err := client.ListGroups(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %v", err)
+	}
+}
+
+func TestWithClientCredentialsValidation(t *testing.T) {
+	if _, err := NewClient("https://example.shieldoo.net", WithClientCredentials(ClientCredentialsConfig{ClientID: "id", ClientSecret: "secret"})); err == nil {
+		t.Fatal("expected missing token URL to be rejected")
+	}
+	if _, err := NewClient("https://example.shieldoo.net", WithClientCredentials(ClientCredentialsConfig{TokenURL: "https://idp.example.com/token", ClientID: "id"})); err == nil {
+		t.Fatal("expected missing client secret to be rejected")
+	}
+}
@@ -0,0 +1,460 @@
+package shieldoo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const offlineEndpointPrefix = "file://"
+
+// offlineState is the content of the offline backend state file.
+type offlineState struct {
+	Groups    []Group    `json:"groups"`
+	Servers   []Server   `json:"servers"`
+	Firewalls []Firewall `json:"firewalls"`
+}
+
+// OfflineBackend emulates the Shieldoo CLI API without a tenant, keeping
+// groups, servers and firewalls in a local JSON file or in memory. Groups
+// referenced by name are created on demand.
+type OfflineBackend struct {
+	// path of the state file, the state is kept in memory when empty
+	path   string
+	memory []byte
+	mu     sync.Mutex
+}
+
+var _ Backend = &OfflineBackend{}
+
+// IsOfflineEndpoint reports whether the endpoint selects the offline backend.
+func IsOfflineEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, offlineEndpointPrefix)
+}
+
+// NewFileBackend creates an offline backend persisting its state in the file
+// of a file:// endpoint, e.g. file:///tmp/shieldoo.json.
+func NewFileBackend(endpoint string) (*OfflineBackend, error) {
+	path, err := offlineFilePath(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, errors.New("offline endpoint must contain a file path, e.g. file:///tmp/shieldoo.json")
+	}
+	return &OfflineBackend{path: path}, nil
+}
+
+// offlineFilePath converts a file:// endpoint to a local file path. Besides
+// file:///abs/path it accepts file:///C:/path and file://C:/path on Windows
+// and a relative file://path.
+func offlineFilePath(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid offline endpoint: %w", err)
+	}
+	path := u.Path
+	if u.Host != "" && u.Host != "localhost" {
+		// not a host name, the path starts right after file://
+		path = u.Host + path
+	}
+	// drop the slash in front of a drive letter, /C:/state.json
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' &&
+		('a' <= path[1] && path[1] <= 'z' || 'A' <= path[1] && path[1] <= 'Z') {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// NewMemoryBackend creates an offline backend keeping its state in memory,
+// which is useful in tests. The groups are available from the start.
+func NewMemoryBackend(groups ...Group) *OfflineBackend {
+	b := &OfflineBackend{}
+	// marshalling plain structs cannot fail
+	_ = b.save(&offlineState{Groups: groups})
+	return b
+}
+
+func (b *OfflineBackend) load() (*offlineState, error) {
+	state := &offlineState{}
+	data := b.memory
+	if b.path != "" {
+		var err error
+		data, err = os.ReadFile(b.path)
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return state, nil
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid offline state file %s: %w", b.path, err)
+	}
+	return state, nil
+}
+
+func (b *OfflineBackend) save(state *offlineState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if b.path == "" {
+		b.memory = data
+		return nil
+	}
+	if dir := filepath.Dir(b.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	// write to a temporary file first, so a crash never leaves a broken state
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// Call implements Backend.
+func (b *OfflineBackend) Call(ctx context.Context, method string, entity string, name string, id string, body []byte) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tflog.SubsystemDebug(ctx, logSubsystem, "calling offline Shieldoo backend", map[string]interface{}{"path": b.path})
+
+	state, err := b.load()
+	if err != nil {
+		return "", err
+	}
+
+	ret, err := state.call(method, entity, name, id, body)
+	if err != nil {
+		return "", err
+	}
+
+	if method != http.MethodGet {
+		if err := b.save(state); err != nil {
+			return "", err
+		}
+	}
+
+	if ret == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(ret)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// call applies one CLI API call to the state and returns the response object.
+func (state *offlineState) call(method string, entity string, name string, id string, body []byte) (interface{}, error) {
+	switch entity {
+	case "groups":
+		return state.callGroups(method, name, id)
+	case "servers":
+		return state.callServers(method, name, id, body)
+	case "firewalls":
+		return state.callFirewalls(method, name, id, body)
+	}
+	return nil, offlineError(http.StatusNotFound, "unknown entity %s", entity)
+}
+
+// groups are read only, they are created on demand by servers and firewalls.
+func (state *offlineState) callGroups(method string, name string, id string) (interface{}, error) {
+	if method != http.MethodGet {
+		return nil, offlineError(http.StatusMethodNotAllowed, "groups are read only")
+	}
+	if id != "" {
+		for _, g := range state.Groups {
+			if g.Id == id {
+				return g, nil
+			}
+		}
+		return nil, offlineError(http.StatusNotFound, "group %s not found", id)
+	}
+	groups := []Group{}
+	for _, g := range state.Groups {
+		if name == "" || g.Name == name {
+			groups = append(groups, g)
+		}
+	}
+	return groups, nil
+}
+
+func (state *offlineState) callServers(method string, name string, id string, body []byte) (interface{}, error) {
+	switch method {
+	case http.MethodGet:
+		if id != "" {
+			i := findOfflineServer(state, id)
+			if i < 0 {
+				return nil, offlineError(http.StatusNotFound, "server %s not found", id)
+			}
+			return state.Servers[i], nil
+		}
+		servers := []Server{}
+		for _, s := range state.Servers {
+			if name == "" || s.Name == name {
+				servers = append(servers, s)
+			}
+		}
+		return servers, nil
+	case http.MethodPost:
+		var server Server
+		if err := json.Unmarshal(body, &server); err != nil {
+			return nil, offlineError(http.StatusBadRequest, "invalid server: %s", err)
+		}
+		for _, s := range state.Servers {
+			if s.Name == server.Name {
+				return nil, offlineError(http.StatusConflict, "server %s already exists", server.Name)
+			}
+		}
+		server.Id = newOfflineId()
+		server.Configuration = base64.StdEncoding.EncodeToString([]byte("offline-configuration-" + server.Id))
+		if err := prepareOfflineServer(state, &server); err != nil {
+			return nil, err
+		}
+		state.Servers = append(state.Servers, server)
+		return server, nil
+	case http.MethodPut:
+		i := findOfflineServer(state, id)
+		if i < 0 {
+			return nil, offlineError(http.StatusNotFound, "server %s not found", id)
+		}
+		var server Server
+		if err := json.Unmarshal(body, &server); err != nil {
+			return nil, offlineError(http.StatusBadRequest, "invalid server: %s", err)
+		}
+		for _, s := range state.Servers {
+			if s.Name == server.Name && s.Id != id {
+				return nil, offlineError(http.StatusConflict, "server %s already exists", server.Name)
+			}
+		}
+		server.Id = id
+		server.Configuration = state.Servers[i].Configuration
+		if server.IpAddress == "" {
+			server.IpAddress = state.Servers[i].IpAddress
+		}
+		if err := prepareOfflineServer(state, &server); err != nil {
+			return nil, err
+		}
+		state.Servers[i] = server
+		return server, nil
+	case http.MethodDelete:
+		i := findOfflineServer(state, id)
+		if i < 0 {
+			return nil, offlineError(http.StatusNotFound, "server %s not found", id)
+		}
+		state.Servers = append(state.Servers[:i], state.Servers[i+1:]...)
+		return nil, nil
+	}
+	return nil, offlineError(http.StatusMethodNotAllowed, "method %s not allowed", method)
+}
+
+func (state *offlineState) callFirewalls(method string, name string, id string, body []byte) (interface{}, error) {
+	switch method {
+	case http.MethodGet:
+		if id != "" {
+			i := findOfflineFirewall(state, id)
+			if i < 0 {
+				return nil, offlineError(http.StatusNotFound, "firewall %s not found", id)
+			}
+			return state.Firewalls[i], nil
+		}
+		firewalls := []Firewall{}
+		for _, f := range state.Firewalls {
+			if name == "" || f.Name == name {
+				firewalls = append(firewalls, f)
+			}
+		}
+		return firewalls, nil
+	case http.MethodPost:
+		var firewall Firewall
+		if err := json.Unmarshal(body, &firewall); err != nil {
+			return nil, offlineError(http.StatusBadRequest, "invalid firewall: %s", err)
+		}
+		for _, f := range state.Firewalls {
+			if f.Name == firewall.Name {
+				return nil, offlineError(http.StatusConflict, "firewall %s already exists", firewall.Name)
+			}
+		}
+		firewall.Id = newOfflineId()
+		if err := prepareOfflineFirewall(state, &firewall); err != nil {
+			return nil, err
+		}
+		state.Firewalls = append(state.Firewalls, firewall)
+		return firewall, nil
+	case http.MethodPut:
+		i := findOfflineFirewall(state, id)
+		if i < 0 {
+			return nil, offlineError(http.StatusNotFound, "firewall %s not found", id)
+		}
+		var firewall Firewall
+		if err := json.Unmarshal(body, &firewall); err != nil {
+			return nil, offlineError(http.StatusBadRequest, "invalid firewall: %s", err)
+		}
+		for _, f := range state.Firewalls {
+			if f.Name == firewall.Name && f.Id != id {
+				return nil, offlineError(http.StatusConflict, "firewall %s already exists", firewall.Name)
+			}
+		}
+		firewall.Id = id
+		if err := prepareOfflineFirewall(state, &firewall); err != nil {
+			return nil, err
+		}
+		state.Firewalls[i] = firewall
+		// servers embed their firewall, keep them in sync
+		for j := range state.Servers {
+			if state.Servers[j].Firewall.Id == id {
+				state.Servers[j].Firewall = firewall
+			}
+		}
+		return firewall, nil
+	case http.MethodDelete:
+		i := findOfflineFirewall(state, id)
+		if i < 0 {
+			return nil, offlineError(http.StatusNotFound, "firewall %s not found", id)
+		}
+		for _, s := range state.Servers {
+			if s.Firewall.Id == id {
+				return nil, offlineError(http.StatusConflict, "firewall %s is used by server %s", id, s.Name)
+			}
+		}
+		state.Firewalls = append(state.Firewalls[:i], state.Firewalls[i+1:]...)
+		return nil, nil
+	}
+	return nil, offlineError(http.StatusMethodNotAllowed, "method %s not allowed", method)
+}
+
+func prepareOfflineServer(state *offlineState, server *Server) error {
+	i := findOfflineFirewall(state, server.Firewall.Id)
+	if i < 0 {
+		return offlineError(http.StatusBadRequest, "firewall %s not found", server.Firewall.Id)
+	}
+	server.Firewall = state.Firewalls[i]
+	groups, err := resolveOfflineGroups(state, server.Groups)
+	if err != nil {
+		return err
+	}
+	server.Groups = groups
+	if server.Listeners == nil {
+		server.Listeners = []Listener{}
+	}
+	if server.IpAddress == "" {
+		ip, err := allocateOfflineIpAddress(state)
+		if err != nil {
+			return err
+		}
+		server.IpAddress = ip
+	}
+	return nil
+}
+
+func prepareOfflineFirewall(state *offlineState, firewall *Firewall) error {
+	for _, rules := range [][]FirewallRule{firewall.RulesIn, firewall.RulesOut} {
+		for i := range rules {
+			groups, err := resolveOfflineGroups(state, rules[i].Groups)
+			if err != nil {
+				return err
+			}
+			rules[i].Groups = groups
+		}
+	}
+	if firewall.RulesIn == nil {
+		firewall.RulesIn = []FirewallRule{}
+	}
+	if firewall.RulesOut == nil {
+		firewall.RulesOut = []FirewallRule{}
+	}
+	return nil
+}
+
+// resolveOfflineGroups fills in all group fields, groups referenced only by
+// name are created on the fly.
+func resolveOfflineGroups(state *offlineState, refs []Group) ([]Group, error) {
+	groups := []Group{}
+	for _, ref := range refs {
+		found := false
+		for _, g := range state.Groups {
+			if (ref.Id != "" && g.Id == ref.Id) ||
+				(ref.ObjectId != "" && g.ObjectId == ref.ObjectId) ||
+				(ref.Id == "" && ref.ObjectId == "" && ref.Name != "" && g.Name == ref.Name) {
+				groups = append(groups, g)
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		if ref.Id != "" || ref.ObjectId != "" || ref.Name == "" {
+			return nil, offlineError(http.StatusBadRequest, "group %v not found", ref)
+		}
+		g := Group{Id: newOfflineId(), Name: ref.Name, ObjectId: newOfflineId()}
+		state.Groups = append(state.Groups, g)
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+func allocateOfflineIpAddress(state *offlineState) (string, error) {
+	used := map[string]bool{}
+	for _, s := range state.Servers {
+		used[s.IpAddress] = true
+	}
+	for i := 10; i < 65535; i++ {
+		ip := fmt.Sprintf("100.64.%d.%d", i/256, i%256)
+		if i%256 == 0 || i%256 == 255 {
+			continue
+		}
+		if !used[ip] {
+			return ip, nil
+		}
+	}
+	return "", offlineError(http.StatusConflict, "no free IP address")
+}
+
+func findOfflineServer(state *offlineState, id string) int {
+	for i, s := range state.Servers {
+		if s.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func findOfflineFirewall(state *offlineState, id string) int {
+	for i, f := range state.Firewalls {
+		if f.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func newOfflineId() string {
+	return newIdempotencyKey()
+}
+
+func offlineError(status int, format string, args ...interface{}) error {
+	return &APIError{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Message:    fmt.Sprintf(format, args...),
+	}
+}
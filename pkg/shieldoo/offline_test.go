@@ -0,0 +1,111 @@
+package shieldoo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestOfflineBackendPersistsState(t *testing.T) {
+	ctx := context.Background()
+	endpoint := "file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "state.json"))
+
+	backend, err := NewFileBackend(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{backend: backend, uri: endpoint}
+
+	fw, err := client.CreateFirewall(ctx, &Firewall{
+		Name:    "default",
+		RulesIn: []FirewallRule{{Protocol: "tcp", Port: "22", Host: "group", Groups: []Group{{Name: "admins"}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := client.CreateServer(ctx, &Server{Name: "web-01", Firewall: Firewall{Id: fw.Id}, Groups: []Group{{Name: "admins"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Id == "" || srv.IpAddress == "" || srv.Configuration == "" {
+		t.Fatalf("expected generated id, ip address and configuration, got %+v", srv)
+	}
+
+	// a new backend instance reads the same file, like the next terraform run
+	backend, err = NewFileBackend(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &Client{backend: backend, uri: endpoint}
+	got, err := client.GetServer(ctx, "web-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != srv.Id || len(got.Groups) != 1 || got.Groups[0].Id != fw.RulesIn[0].Groups[0].Id {
+		t.Fatalf("unexpected server read back: %+v", got)
+	}
+
+	if err := client.DeleteFirewall(ctx, fw.Id); err == nil {
+		t.Fatal("expected firewall in use to be rejected")
+	}
+	if err := client.DeleteServer(ctx, srv.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteServer(ctx, srv.Id); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if _, err := client.GetServer(ctx, "web-01"); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestOfflineBackendReadsByID(t *testing.T) {
+	ctx := context.Background()
+	endpoint := "file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "state.json"))
+	backend, err := NewFileBackend(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{backend: backend, uri: endpoint}
+
+	fw, err := client.CreateFirewall(ctx, &Firewall{Name: "before"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Name = "after"
+	if _, err := client.UpdateFirewall(ctx, fw); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetFirewallByID(ctx, fw.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "after" {
+		t.Fatalf("expected renamed firewall, got %q", got.Name)
+	}
+	if _, err := client.GetServerByID(ctx, "missing"); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestOfflineFilePath(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"file:///tmp/shieldoo.json":          "/tmp/shieldoo.json",
+		"file://localhost/tmp/shieldoo.json": "/tmp/shieldoo.json",
+		"file:///tmp/my%20state.json":        "/tmp/my state.json",
+		"file:///C:/shieldoo/state.json":     "C:/shieldoo/state.json",
+		"file://C:/shieldoo/state.json":      "C:/shieldoo/state.json",
+		"file://state/shieldoo.json":         "state/shieldoo.json",
+	} {
+		got, err := offlineFilePath(endpoint)
+		if err != nil {
+			t.Fatalf("%s: %s", endpoint, err)
+		}
+		if got != filepath.FromSlash(want) {
+			t.Fatalf("%s: expected %s, got %s", endpoint, filepath.FromSlash(want), got)
+		}
+	}
+	if _, err := NewFileBackend("file://"); err == nil {
+		t.Fatal("expected an endpoint without path to be rejected")
+	}
+}
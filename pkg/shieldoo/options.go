@@ -0,0 +1,255 @@
+package shieldoo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Option configures a Client.
+type Option func(*Client) error
+
+// NewClient creates a client of the Shieldoo instance at endpoint, e.g.
+// https://mytenant.shieldoo.net. The endpoint may contain a path prefix of a
+// reverse proxy (https://gw.example.com/corp/shieldoo), the API is then
+// called below it. Endpoints starting with file:// use the offline backend,
+// see NewFileBackend.
+func NewClient(endpoint string, opts ...Option) (*Client, error) {
+	if endpoint == "" {
+		return nil, errors.New("endpoint is required")
+	}
+	uri := strings.TrimSuffix(endpoint, "/")
+	// the API path is appended by the client, accept it in the endpoint too
+	uri = strings.TrimSuffix(uri, "/cliapi")
+	c := &Client{
+		uri:           uri,
+		maxRetries:    DefaultMaxRetries,
+		retryMaxWait:  DefaultRetryMaxWait,
+		tokenLifetime: DefaultTokenLifetime,
+	}
+	if IsOfflineEndpoint(endpoint) {
+		backend, err := NewFileBackend(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		c.backend = backend
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	if c.backend == nil && c.apiKey == "" && c.auth == nil {
+		return nil, errors.New("API key, client credentials or workload identity are required")
+	}
+	if c.httpClient == nil {
+		httpClient, err := NewHTTPClient(TransportConfig{})
+		if err != nil {
+			return nil, err
+		}
+		c.httpClient = httpClient
+	}
+	return c, nil
+}
+
+// WithAPIKey sets the API key used to sign the access tokens.
+func WithAPIKey(apiKey string) Option {
+	return func(c *Client) error {
+		c.apiKey = apiKey
+		return nil
+	}
+}
+
+// WithSecondaryAPIKey sets a second API key used during key rotation. When
+// the API rejects a request signed with the primary key, the request is sent
+// once more signed with the secondary key, which is then kept for the
+// lifetime of the client.
+func WithSecondaryAPIKey(apiKey string) Option {
+	return func(c *Client) error {
+		c.secondaryAPIKey = apiKey
+		return nil
+	}
+}
+
+// WithClientCredentials authenticates with bearer tokens obtained by the
+// OAuth2 client credentials grant instead of the API key. The tokens are
+// cached and refreshed shortly before they expire or when the API rejects them.
+func WithClientCredentials(cfg ClientCredentialsConfig) Option {
+	return func(c *Client) error {
+		if cfg.TokenURL == "" {
+			return errors.New("token URL is required")
+		}
+		if _, err := url.ParseRequestURI(cfg.TokenURL); err != nil {
+			return fmt.Errorf("invalid token URL: %w", err)
+		}
+		if cfg.ClientID == "" || cfg.ClientSecret == "" {
+			return errors.New("client ID and client secret are required")
+		}
+		cfg.Scopes = append([]string(nil), cfg.Scopes...)
+		c.auth = &clientCredentialsSource{cfg: cfg}
+		return nil
+	}
+}
+
+// WithWorkloadIdentity authenticates with a session token obtained by
+// exchanging a CI issued OIDC token, see WorkloadIdentityConfig. The session
+// token is cached and exchanged again shortly before it expires or when the
+// API rejects it.
+func WithWorkloadIdentity(cfg WorkloadIdentityConfig) Option {
+	return func(c *Client) error {
+		if cfg.ExchangeURL == "" {
+			cfg.ExchangeURL = c.uri + DefaultTokenExchangePath
+		}
+		if _, err := url.ParseRequestURI(cfg.ExchangeURL); err != nil {
+			return fmt.Errorf("invalid token exchange URL: %w", err)
+		}
+		if cfg.Audience == "" {
+			cfg.Audience = DefaultWorkloadIdentityAudience
+		}
+		c.auth = &workloadIdentitySource{cfg: cfg}
+		return nil
+	}
+}
+
+// WithInstance sets the instance claim of the signed access tokens, by
+// default it is the endpoint hostname. Set it when the API is reached
+// through a proxy whose hostname differs from the tenant domain.
+func WithInstance(instance string) Option {
+	return func(c *Client) error {
+		c.instance = instance
+		return nil
+	}
+}
+
+// WithBackend replaces the HTTP API with the given backend.
+func WithBackend(backend Backend) Option {
+	return func(c *Client) error {
+		c.backend = backend
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for all API calls.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithTransport builds the HTTP client from the transport configuration.
+func WithTransport(cfg TransportConfig) Option {
+	return func(c *Client) error {
+		httpClient, err := NewHTTPClient(cfg)
+		if err != nil {
+			return err
+		}
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithRetries sets how many times transient failures are retried and the
+// longest wait between two attempts.
+func WithRetries(maxRetries int, maxWait time.Duration) Option {
+	return func(c *Client) error {
+		if maxRetries < 0 {
+			return errors.New("max retries must not be negative")
+		}
+		if maxWait <= 0 {
+			return errors.New("max retry wait must be positive")
+		}
+		c.maxRetries = maxRetries
+		c.retryMaxWait = maxWait
+		return nil
+	}
+}
+
+// WithIdempotentPost sends an Idempotency-Key header with every POST request,
+// the same key with each attempt. Use it only with backends that honour the
+// header, POST requests are then retried like any other request. Without it
+// POST requests are not retried.
+func WithIdempotentPost() Option {
+	return func(c *Client) error {
+		c.idempotentPost = true
+		return nil
+	}
+}
+
+// WithRateLimit limits the number of API requests per second.
+func WithRateLimit(requestsPerSecond float64) Option {
+	return func(c *Client) error {
+		if requestsPerSecond <= 0 {
+			return errors.New("requests per second must be positive")
+		}
+		c.limiter = newRateLimiter(requestsPerSecond)
+		return nil
+	}
+}
+
+// WithMaxConcurrentRequests limits the number of API requests in flight.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(c *Client) error {
+		if n < 1 {
+			return errors.New("max concurrent requests must be at least 1")
+		}
+		c.requests = newSemaphore(n)
+		return nil
+	}
+}
+
+// WithCache caches read-only API responses for ttl, writes invalidate the
+// cached responses of the written entity type.
+func WithCache(ttl time.Duration) Option {
+	return func(c *Client) error {
+		if ttl < 0 {
+			return errors.New("cache TTL must not be negative")
+		}
+		c.cache = nil
+		if ttl > 0 {
+			c.cache = newResponseCache(ttl)
+		}
+		return nil
+	}
+}
+
+// WithTokenLifetime sets the lifetime of the signed API access tokens.
+func WithTokenLifetime(lifetime time.Duration) Option {
+	return func(c *Client) error {
+		if lifetime < 10*time.Second {
+			return errors.New("token lifetime must be at least 10 seconds")
+		}
+		c.tokenLifetime = lifetime
+		return nil
+	}
+}
+
+// WithTokenClockSkew backdates the iat/nbf claims of the access tokens, so
+// servers with a clock behind the local one accept them.
+func WithTokenClockSkew(skew time.Duration) Option {
+	return func(c *Client) error {
+		if skew < 0 {
+			return errors.New("token clock skew must not be negative")
+		}
+		c.tokenClockSkew = skew
+		return nil
+	}
+}
+
+// WithTokenClaims adds claims to the shieldoo claim of the access tokens.
+// The instance claim is set with WithInstance.
+func WithTokenClaims(claims map[string]string) Option {
+	return func(c *Client) error {
+		if _, ok := claims["instance"]; ok {
+			return errors.New("the instance claim cannot be set in token claims, it is set with WithInstance")
+		}
+		c.tokenClaims = map[string]string{}
+		for k, v := range claims {
+			c.tokenClaims[k] = v
+		}
+		return nil
+	}
+}
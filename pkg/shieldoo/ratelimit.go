@@ -0,0 +1,79 @@
+package shieldoo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by all API calls of one client.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(requestsPerSecond float64) *rateLimiter {
+	burst := requestsPerSecond
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   requestsPerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request may be sent or the context is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	// reserve the token, the balance may go negative and is paid back by waiting
+	l.tokens--
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	if err := sleepContext(ctx, wait); err != nil {
+		// give the reservation back, the request will not be sent
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// semaphore limits the number of API requests in flight.
+type semaphore chan struct{}
+
+func newSemaphore(size int) semaphore {
+	return make(semaphore, size)
+}
+
+func (s semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) Release() {
+	<-s
+}
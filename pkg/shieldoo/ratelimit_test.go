@@ -0,0 +1,71 @@
+package shieldoo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterThrottles(t *testing.T) {
+	limiter := newRateLimiter(20)
+	ctx := context.Background()
+	start := time.Now()
+	// the first 20 requests use the burst, the next 10 need half a second
+	for i := 0; i < 30; i++ {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("expected requests to be throttled, took only %s", elapsed)
+	}
+}
+
+func TestRateLimiterHonoursContext(t *testing.T) {
+	limiter := newRateLimiter(0.1)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := limiter.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := limiter.Wait(ctx); err == nil {
+		t.Fatal("expected cancelled context error")
+	}
+}
+
+func TestConcurrentRequestsAreCapped(t *testing.T) {
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	client := &Client{uri: srv.URL, apiKey: "test", requests: newSemaphore(2)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.ListGroups(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxInFlight > 2 {
+		t.Fatalf("expected at most 2 concurrent requests, got %d", maxInFlight)
+	}
+}
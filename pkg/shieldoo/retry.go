@@ -0,0 +1,118 @@
+package shieldoo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is the number of retries of transient API failures.
+	DefaultMaxRetries = 3
+	// DefaultRetryMaxWait is the longest wait between two retries.
+	DefaultRetryMaxWait = 30 * time.Second
+
+	retryBaseWait = 1 * time.Second
+)
+
+// isRetryableRequest decides whether a failed call may be sent again.
+// Only idempotent verbs, or POSTs guarded by an idempotency key, are retried,
+// an unguarded POST may already have been applied by the API.
+func isRetryableRequest(ctx context.Context, method string, idempotencyKey string, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		if idempotencyKey == "" {
+			return false
+		}
+	}
+	if err != nil {
+		var authErr *AuthError
+		if errors.As(err, &authErr) {
+			return false
+		}
+		// transport level failure (connection reset, timeout, ...)
+		return resp == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryWait returns how long to wait before the next attempt, honouring
+// Retry-After when the server sends it and exponential backoff with full
+// jitter otherwise.
+func (c *Client) retryWait(attempt int, resp *http.Response) time.Duration {
+	maxWait := c.retryMaxWait
+	if maxWait <= 0 {
+		maxWait = DefaultRetryMaxWait
+	}
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > maxWait {
+				return maxWait
+			}
+			return wait
+		}
+	}
+	backoff := retryBaseWait << uint(attempt)
+	if backoff <= 0 || backoff > maxWait {
+		backoff = maxWait
+	}
+	jitter, err := rand.Int(rand.Reader, big.NewInt(int64(backoff)))
+	if err != nil {
+		return backoff
+	}
+	return time.Duration(jitter.Int64())
+}
+
+// parseRetryAfter understands both forms of the header: delay in seconds
+// and an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
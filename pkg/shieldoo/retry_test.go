@@ -0,0 +1,94 @@
+package shieldoo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	if wait, ok := parseRetryAfter("3"); !ok || wait != 3*time.Second {
+		t.Fatalf("expected 3s, got %v %v", wait, ok)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Fatal("expected invalid Retry-After to be ignored")
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if wait, ok := parseRetryAfter(date); !ok || wait <= 0 {
+		t.Fatalf("expected positive wait for HTTP date, got %v %v", wait, ok)
+	}
+}
+
+func TestCallApiRetriesTransientFailures(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	client := &Client{uri: srv.URL, apiKey: "test", maxRetries: 3, retryMaxWait: time.Second}
+	if _, err := client.ListGroups(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestCallApiDoesNotRetryUnguardedPost(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		calls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if r.Header.Get("Idempotency-Key") != "" {
+				t.Errorf("unexpected Idempotency-Key header")
+			}
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+		}))
+
+		client := &Client{uri: srv.URL, apiKey: "test", maxRetries: 3, retryMaxWait: time.Second}
+		if _, err := client.send(context.Background(), http.MethodPost, "servers", "", "", []byte(`{}`)); err == nil {
+			t.Fatalf("%d: expected an error", status)
+		}
+		srv.Close()
+		// the POST may already have been applied, sending it again could create a duplicate
+		if calls != 1 {
+			t.Fatalf("%d: POST must not be sent again, got %d calls", status, calls)
+		}
+	}
+}
+
+func TestCallApiRetriesIdempotentPost(t *testing.T) {
+	calls := 0
+	keys := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		keys[r.Header.Get("Idempotency-Key")] = true
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client := &Client{uri: srv.URL, apiKey: "test", maxRetries: 3, retryMaxWait: time.Millisecond}
+	if err := WithIdempotentPost()(client); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.send(context.Background(), http.MethodPost, "servers", "", "", []byte(`{}`)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls != 2 || len(keys) != 1 || keys[""] {
+		t.Fatalf("expected 2 calls with the same Idempotency-Key, got %d calls, keys %v", calls, keys)
+	}
+}
@@ -0,0 +1,111 @@
+package shieldoo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const defaultRequestTimeout = 60 * time.Second
+
+// TransportConfig holds the settings of the HTTP client shared by
+// all API calls.
+type TransportConfig struct {
+	CACertFile         string
+	CACertPEM          string
+	ClientCertFile     string
+	ClientKeyFile      string
+	ClientCertPEM      string
+	ClientKeyPEM       string
+	ProxyURL           string
+	InsecureSkipVerify bool
+	RequestTimeout     time.Duration
+}
+
+// NewHTTPClient builds the HTTP client from the transport configuration.
+// The client is created once per Client and reused, so connections are
+// kept alive across requests.
+func NewHTTPClient(cfg TransportConfig) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CACertFile != "" || cfg.CACertPEM != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if cfg.CACertFile != "" {
+			pem, err := os.ReadFile(cfg.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read CA certificate file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA certificate file %s", cfg.CACertFile)
+			}
+		}
+		if cfg.CACertPEM != "" {
+			if !pool.AppendCertsFromPEM([]byte(cfg.CACertPEM)) {
+				return nil, errors.New("no certificates found in CA certificate PEM")
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	certPEM := []byte(cfg.ClientCertPEM)
+	keyPEM := []byte(cfg.ClientKeyPEM)
+	if cfg.ClientCertFile != "" {
+		data, err := os.ReadFile(cfg.ClientCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client certificate file: %w", err)
+		}
+		certPEM = data
+	}
+	if cfg.ClientKeyFile != "" {
+		data, err := os.ReadFile(cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client key file: %w", err)
+		}
+		keyPEM = data
+	}
+	if len(certPEM) > 0 || len(keyPEM) > 0 {
+		if len(certPEM) == 0 || len(keyPEM) == 0 {
+			return nil, errors.New("both client certificate and client key must be set")
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate or key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("unexpected default HTTP transport type")
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConnsPerHost = 10
+	if cfg.ProxyURL != "" {
+		proxy, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	timeout := cfg.RequestTimeout
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
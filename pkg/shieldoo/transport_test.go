@@ -0,0 +1,45 @@
+package shieldoo
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewHTTPClientTrustsCustomCA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	untrusted, err := NewHTTPClient(TransportConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{uri: srv.URL, apiKey: "test", httpClient: untrusted}
+	if _, err := client.ListGroups(context.Background()); err == nil {
+		t.Fatal("expected TLS verification error without custom CA")
+	}
+
+	trusted, err := NewHTTPClient(TransportConfig{CACertPEM: string(caPEM)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.httpClient = trusted
+	if _, err := client.ListGroups(context.Background()); err != nil {
+		t.Fatalf("unexpected error with custom CA: %s", err)
+	}
+}
+
+func TestNewHTTPClientRejectsInvalidSettings(t *testing.T) {
+	if _, err := NewHTTPClient(TransportConfig{CACertPEM: "not a certificate"}); err == nil {
+		t.Fatal("expected error for invalid CA certificate PEM")
+	}
+	if _, err := NewHTTPClient(TransportConfig{ClientCertPEM: "cert"}); err == nil {
+		t.Fatal("expected error for client certificate without key")
+	}
+}
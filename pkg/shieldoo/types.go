@@ -0,0 +1,58 @@
+package shieldoo
+
+// Group is a Shieldoo user group. References to groups may use any of the
+// fields, the API resolves the rest.
+type Group struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	ObjectId string `json:"objectId"`
+}
+
+// FirewallRule is a single inbound or outbound firewall rule.
+type FirewallRule struct {
+	Protocol string  `json:"protocol"`
+	Port     string  `json:"port"`
+	Host     string  `json:"host"`
+	Groups   []Group `json:"groups"`
+}
+
+// Firewall is a named set of firewall rules assigned to servers.
+type Firewall struct {
+	Id       string         `json:"id"`
+	Name     string         `json:"name"`
+	RulesIn  []FirewallRule `json:"rulesIn"`
+	RulesOut []FirewallRule `json:"rulesOut"`
+}
+
+// Listener forwards a port of the server to another host.
+type Listener struct {
+	ListenPort  int    `json:"listenPort"`
+	Protocol    string `json:"protocol"`
+	ForwardPort int    `json:"forwardPort"`
+	ForwardHost string `json:"forwardHost"`
+	Description string `json:"description"`
+}
+
+// Server is a Shieldoo server, Configuration is the secret used to join
+// the server to the network.
+type Server struct {
+	Id             string                   `json:"id"`
+	Name           string                   `json:"name"`
+	Groups         []Group                  `json:"groups"`
+	Firewall       Firewall                 `json:"firewall"`
+	Listeners      []Listener               `json:"listeners"`
+	Autoupdate     bool                     `json:"autoupdate"`
+	IpAddress      string                   `json:"ipAddress"`
+	Description    string                   `json:"description"`
+	Configuration  string                   `json:"configuration"`
+	OSUpdatePolicy ServerOSAutoupdatePolicy `json:"osUpdatePolicy"`
+}
+
+// ServerOSAutoupdatePolicy controls operating system updates of a server.
+type ServerOSAutoupdatePolicy struct {
+	Enabled                   bool `json:"enabled"`
+	SecurityAutoupdateEnabled bool `json:"securityAutoupdateEnabled"`
+	AllAutoupdateEnabled      bool `json:"allAutoupdateEnabled"`
+	RestartAfterUpdate        bool `json:"restartAfterUpdate"`
+	UpdateHour                int  `json:"updateHour"`
+}
@@ -0,0 +1,136 @@
+package shieldoo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// DefaultTokenExchangePath is the path of the Shieldoo token exchange
+// endpoint used when WorkloadIdentityConfig.ExchangeURL is empty.
+const DefaultTokenExchangePath = "/cliapi/token"
+
+// DefaultWorkloadIdentityAudience is the audience requested for GitHub
+// Actions OIDC tokens.
+const DefaultWorkloadIdentityAudience = "shieldoo"
+
+// WorkloadIdentityConfig configures the exchange of a CI issued OIDC token
+// for a Shieldoo session token (RFC 8693 token exchange).
+//
+// The OIDC token is taken from the first available source: Token,
+// TokenFile, the TFC_WORKLOAD_IDENTITY_TOKEN environment variable of
+// Terraform Cloud and finally the GitHub Actions OIDC token endpoint.
+type WorkloadIdentityConfig struct {
+	// ExchangeURL defaults to the endpoint with DefaultTokenExchangePath.
+	ExchangeURL string
+	Token       string
+	TokenFile   string
+	// Audience is requested for GitHub Actions tokens, default
+	// DefaultWorkloadIdentityAudience.
+	Audience string
+}
+
+type workloadIdentitySource struct {
+	cfg    WorkloadIdentityConfig
+	cached bearerToken
+}
+
+func (s *workloadIdentitySource) Token(ctx context.Context, httpClient *http.Client) (string, error) {
+	return s.cached.get(func() (string, time.Duration, error) {
+		// the OIDC token is read on every exchange, CI systems may rotate it
+		subjectToken, source, err := s.subjectToken(ctx, httpClient)
+		if err != nil {
+			return "", 0, err
+		}
+		tflog.SubsystemDebug(ctx, logSubsystem, "exchanging workload identity token", map[string]interface{}{
+			"exchange_url": s.cfg.ExchangeURL,
+			"token_source": source,
+		})
+		form := url.Values{}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange")
+		form.Set("subject_token", subjectToken)
+		form.Set("subject_token_type", "urn:ietf:params:oauth:token-type:jwt")
+		return requestToken(ctx, httpClient, s.cfg.ExchangeURL, form)
+	})
+}
+
+func (s *workloadIdentitySource) Invalidate(token string) {
+	s.cached.invalidate(token)
+}
+
+// subjectToken returns the OIDC token to exchange and a description of
+// where it was found.
+func (s *workloadIdentitySource) subjectToken(ctx context.Context, httpClient *http.Client) (string, string, error) {
+	if s.cfg.Token != "" {
+		return s.cfg.Token, "token", nil
+	}
+	if s.cfg.TokenFile != "" {
+		data, err := os.ReadFile(s.cfg.TokenFile)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to read workload identity token: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", "", fmt.Errorf("workload identity token file %s is empty", s.cfg.TokenFile)
+		}
+		return token, "token_file", nil
+	}
+	if token := os.Getenv("TFC_WORKLOAD_IDENTITY_TOKEN"); token != "" {
+		return token, "TFC_WORKLOAD_IDENTITY_TOKEN", nil
+	}
+	requestURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL != "" && requestToken != "" {
+		token, err := githubActionsToken(ctx, httpClient, requestURL, requestToken, s.cfg.Audience)
+		return token, "github_actions", err
+	}
+	return "", "", errors.New("no workload identity token found: no token or token file is configured, TFC_WORKLOAD_IDENTITY_TOKEN is not set and no GitHub Actions OIDC token is available (id-token: write permission)")
+}
+
+// githubActionsToken requests an OIDC token from the GitHub Actions runtime.
+func githubActionsToken(ctx context.Context, httpClient *http.Client, requestURL string, requestToken string, audience string) (string, error) {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("audience", audience)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("GitHub Actions token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("GitHub Actions token request failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("GitHub Actions token request failed with %s", resp.Status)
+	}
+	var ret struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &ret); err != nil {
+		return "", fmt.Errorf("unable to decode GitHub Actions token response: %w", err)
+	}
+	if ret.Value == "" {
+		return "", errors.New("GitHub Actions token response contains no token")
+	}
+	return ret.Value, nil
+}
@@ -0,0 +1,149 @@
+package shieldoo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// newWorkloadIdentityTestServer serves the token exchange and the API, it
+// accepts only the session token issued for the expected OIDC token.
+func newWorkloadIdentityTestServer(t *testing.T, oidcToken string, exchanges *int32) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc(DefaultTokenExchangePath, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(exchanges, 1)
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:token-exchange" || r.PostForm.Get("subject_token") != oidcToken {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"session-1","token_type":"Bearer","expires_in":900}`))
+	})
+	mux.HandleFunc("/cliapi/groups", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer session-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWorkloadIdentityTokenFile(t *testing.T) {
+	var exchanges int32
+	srv := newWorkloadIdentityTestServer(t, "oidc-file", &exchanges)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("oidc-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TFC_WORKLOAD_IDENTITY_TOKEN", "oidc-tfc")
+
+	client, err := NewClient(srv.URL, WithWorkloadIdentity(WorkloadIdentityConfig{TokenFile: tokenFile}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListGroups(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&exchanges); n != 1 {
+		t.Fatalf("expected the session token to be cached, got %d exchanges", n)
+	}
+}
+
+func TestWorkloadIdentityTerraformCloud(t *testing.T) {
+	var exchanges int32
+	srv := newWorkloadIdentityTestServer(t, "oidc-tfc", &exchanges)
+	t.Setenv("TFC_WORKLOAD_IDENTITY_TOKEN", "oidc-tfc")
+
+	client, err := NewClient(srv.URL, WithWorkloadIdentity(WorkloadIdentityConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListGroups(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkloadIdentityGitHubActions(t *testing.T) {
+	var exchanges int32
+	srv := newWorkloadIdentityTestServer(t, "oidc-github", &exchanges)
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer runtime-token" || r.URL.Query().Get("audience") != "shieldoo" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"value":"oidc-github"}`))
+	}))
+	defer github.Close()
+	t.Setenv("TFC_WORKLOAD_IDENTITY_TOKEN", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", github.URL+"/token?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "runtime-token")
+
+	client, err := NewClient(srv.URL, WithWorkloadIdentity(WorkloadIdentityConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListGroups(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkloadIdentityMissingToken(t *testing.T) {
+	t.Setenv("TFC_WORKLOAD_IDENTITY_TOKEN", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "")
+	client, err := NewClient("https://example.shieldoo.net", WithWorkloadIdentity(WorkloadIdentityConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var authErr *AuthError
+	if _, err := client.ListGroups(context.Background()); !errors.As(err, &authErr) {
+		t.Fatalf("expected authentication error, got %v", err)
+	}
+}
+
+func TestWorkloadIdentityRejectedSessionIsExchangedAgain(t *testing.T) {
+	var exchanges int32
+	mux := http.NewServeMux()
+	mux.HandleFunc(DefaultTokenExchangePath, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&exchanges, 1)
+		_, _ = fmt.Fprintf(w, `{"access_token":"session-%d","token_type":"Bearer","expires_in":900}`, n)
+	})
+	mux.HandleFunc("/cliapi/groups", func(w http.ResponseWriter, r *http.Request) {
+		// session-1 was revoked before it expired
+		if r.Header.Get("Authorization") != "Bearer session-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	t.Setenv("TFC_WORKLOAD_IDENTITY_TOKEN", "oidc-tfc")
+
+	client, err := NewClient(srv.URL, WithWorkloadIdentity(WorkloadIdentityConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListGroups(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&exchanges); n != 2 {
+		t.Fatalf("expected the rejected session token to be exchanged again, got %d exchanges", n)
+	}
+}